@@ -40,6 +40,9 @@ func (d *{{.|ValueName}}) Set(s string) error {
 // Get returns the value of the {{.|ValueName}}
 func (d *{{.|ValueName}}) Get() interface{} { return ({{.Type}})(*d.v) }
 
+// Pointer returns the pointer the {{.|ValueName}} stores its value at
+func (d *{{.|ValueName}}) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the {{.|ValueName}}
 func (d *{{.|ValueName}}) String() string { return {{.|Format}} }
 {{end}}
@@ -68,6 +71,28 @@ const (
 {{- end}}{{end}}
 )
 
+// String returns the name of the value type, as used in Go.
+func (t ValueType) String() string {
+	switch t {
+{{- range .}}{{if and .Basic (not .NoValueParser)}}
+	case {{.|Name}}:
+		return "{{.Type}}"
+{{- end}}{{end}}
+	}
+	return "ValueType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Help describes the values accepted for the value type, e.g. "a number".
+// It returns "" for types accepting any value.
+func (t ValueType) Help() string {
+	switch t {
+{{- range .}}{{if and .Basic (not .NoValueParser) .Help}}
+	case {{.|Name}}:
+		return "{{.Help}}"
+{{- end}}{{end}}
+	}
+	return ""
+}
 {{range .}}{{if and .Basic (not .NoValueParser)}}
 func {{.|Name}}Arg(target *{{.Type}}, attributes ...ArgAttribute) *ArgDef {
 	return NewArgDef(values.New{{.|ValueName}}(target), {{.|Name}}, attributes...)
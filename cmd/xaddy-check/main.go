@@ -0,0 +1,142 @@
+// Command xaddy-check validates configuration files.
+//
+// Usage:
+//
+//	xaddy-check [flags] file...
+//
+// Each file is read with its imports, snippets, macros and placeholders, and the
+// errors are reported with their positions. All syntax errors of a file are
+// reported: after an error, checking resumes at the next top-level node. Other
+// errors, such as unknown imports or undefined environment variables, are found
+// once a file is free of syntax errors, and reading stops at the first of them.
+// A file named - is read from standard input.
+//
+// With -p, the expanded configuration of valid files is printed: snippets and files
+// imported, macros and placeholders replaced and conditional blocks resolved.
+// Arguments containing secret values, such as those of {file:...} placeholders,
+// are redacted.
+// -origins annotates each printed line with the position it comes from and the
+// snippet and imports it was reached through, e.g.
+//
+//	cert_file /etc/tls/cert.pem # site.conf:14 via import common_tls, imported at app.conf:3
+//
+// The exit code is 0 if all files are valid, 1 if a file has an error, 2 for
+// invalid usage and 3 if a file or an import couldn't be read.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	config "github.com/open-webtech/go-xaddy-config"
+)
+
+const (
+	exitOK = iota
+	exitInvalid
+	exitUsage
+	exitIO
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run checks the files named in args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("xaddy-check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	printTree := flags.Bool("p", false, "print the expanded configuration of valid files")
+	printOrigins := flags.Bool("origins", false, "annotate the printed configuration with the origin of each line, implies -p")
+	strictEnv := flags.Bool("strict-env", false, "report environment variables that are neither defined nor have a default")
+	noFileImports := flags.Bool("no-file-imports", false, "only allow imports of snippets")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xaddy-check [flags] file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var secrets config.Secrets
+	options := []config.ReadOption{config.WithSecrets(&secrets)}
+	if *strictEnv {
+		options = append(options, config.WithStrictEnv())
+	}
+	if *noFileImports {
+		options = append(options, config.WithoutFileImports())
+	}
+
+	var origins config.Origins
+	var annotate func(parser.Node) string
+	if *printOrigins {
+		*printTree = true
+		options = append(options, config.WithOrigins(&origins))
+		annotate = func(node parser.Node) string {
+			return origins.Of(node).String()
+		}
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		src, location, err := load(name, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = max(code, exitIO)
+			continue
+		}
+		ast, err := config.Read(bytes.NewReader(src), location, options...)
+		if err != nil {
+			errs := config.SyntaxErrors(src, location, options...)
+			if len(errs) == 0 {
+				errs = []error{err}
+			}
+			for _, err := range errs {
+				fmt.Fprintln(stderr, secrets.RedactError(err))
+			}
+			code = max(code, exitCode(err))
+			continue
+		}
+		if *printTree {
+			data, err := config.MarshalAnnotated(secrets.Redact(ast), annotate)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", name, err)
+				code = max(code, exitInvalid)
+				continue
+			}
+			stdout.Write(data)
+		}
+	}
+	return code
+}
+
+// load returns the content of the named configuration file, or of standard input
+// for -, and the location to report in errors.
+func load(name string, stdin io.Reader) ([]byte, string, error) {
+	if name == "-" {
+		src, err := io.ReadAll(stdin)
+		return src, "<stdin>", err
+	}
+	src, err := os.ReadFile(name)
+	return src, name, err
+}
+
+// exitCode returns the exit code for a read error. Errors opening or reading a
+// file are I/O errors, everything else is an error in the configuration.
+func exitCode(err error) int {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return exitIO
+	}
+	return exitInvalid
+}
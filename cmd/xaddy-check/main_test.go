@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"valid.conf":   "log_level info\nimport tls\n",
+		"tls.conf":     "tls {\n  cert_file cert.pem\n}\n",
+		"invalid.conf": "server {\n  listen :80\n",
+		"missing.conf": "import nothing\n",
+		"env.conf":     "root {env:XADDY_CHECK_UNDEFINED}\n",
+		"secret.conf":  "password {file:password}\nuser app\n",
+		"password":     "s3cret\n",
+		"errors.conf":  "1abc foo\nok 1\n}\nserver {\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "valid", args: []string{path("valid.conf")}, wantCode: exitOK},
+		{name: "print", args: []string{"-p", path("valid.conf")}, wantCode: exitOK,
+			wantStdout: "log_level info\ntls {\n    cert_file cert.pem\n}\n"},
+		{name: "origins", args: []string{"-origins", path("valid.conf")}, wantCode: exitOK,
+			wantStdout: fmt.Sprintf("log_level info # %[1]s:1\ntls { # %[2]s:1, imported at %[1]s:2\n    cert_file cert.pem # %[2]s:2, imported at %[1]s:2\n}\n",
+				path("valid.conf"), path("tls.conf"))},
+		{name: "print secrets", args: []string{"-p", path("secret.conf")}, wantCode: exitOK,
+			wantStdout: "password [redacted]\nuser app\n"},
+		{name: "syntax error", args: []string{path("invalid.conf")}, wantCode: exitInvalid, wantStderr: "invalid.conf:"},
+		{name: "all syntax errors", args: []string{path("errors.conf")}, wantCode: exitInvalid,
+			wantStderr: fmt.Sprintf("%[1]s:1: directive name 1abc starts with a digit\n%[1]s:3: unexpected \"}\" outside of a block\n%[1]s:4: block is not closed, \"}\" expected before the end of the file\n",
+				path("errors.conf"))},
+		{name: "unknown import", args: []string{path("missing.conf")}, wantCode: exitInvalid, wantStderr: "missing.conf:1: unknown import nothing,"},
+		{name: "env", args: []string{path("env.conf")}, wantCode: exitOK},
+		{name: "strict env", args: []string{"-strict-env", path("env.conf")}, wantCode: exitInvalid, wantStderr: "XADDY_CHECK_UNDEFINED"},
+		{name: "no file imports", args: []string{"-no-file-imports", path("valid.conf")}, wantCode: exitInvalid, wantStderr: "file imports are disabled"},
+		{name: "missing file", args: []string{path("nothing.conf")}, wantCode: exitIO, wantStderr: "nothing.conf"},
+		{name: "worst code wins", args: []string{path("nothing.conf"), path("invalid.conf"), path("valid.conf")}, wantCode: exitIO},
+		{name: "stdin", args: []string{"-p", "-"}, stdin: "a 1\n", wantCode: exitOK, wantStdout: "a 1\n"},
+		{name: "stdin error", args: []string{"-"}, stdin: "a {\n", wantCode: exitInvalid, wantStderr: "<stdin>"},
+		{name: "no files", args: nil, wantCode: exitUsage, wantStderr: "usage:"},
+		{name: "unknown flag", args: []string{"-x", path("valid.conf")}, wantCode: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Errorf("run() = %d, want %d, stderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("run() stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
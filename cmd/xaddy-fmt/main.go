@@ -0,0 +1,267 @@
+// Command xaddy-fmt formats configuration files.
+//
+// Usage:
+//
+//	xaddy-fmt [flags] [path...]
+//
+// Without paths, it formats standard input to standard output. Directories are
+// walked for files ending in .conf. See config.Format for the canonical style.
+//
+// The flags are:
+//
+//	-d	print diffs instead of the formatted source
+//	-l	list files whose formatting differs
+//	-w	write the formatted source back to the files
+//
+// The exit code is 2 if a file couldn't be read, formatted or written, and 0 otherwise.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	config "github.com/open-webtech/go-xaddy-config"
+)
+
+const (
+	exitOK    = 0
+	exitError = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// options holds the command-line flags.
+type options struct {
+	diff, list, write bool
+}
+
+// run formats the paths in args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts options
+	flags := flag.NewFlagSet("xaddy-fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&opts.diff, "d", false, "print diffs instead of the formatted source")
+	flags.BoolVar(&opts.list, "l", false, "list files whose formatting differs")
+	flags.BoolVar(&opts.write, "w", false, "write the formatted source back to the files")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xaddy-fmt [flags] [path...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if flags.NArg() == 0 {
+		if opts.write {
+			fmt.Fprintln(stderr, "xaddy-fmt: cannot use -w with standard input")
+			return exitError
+		}
+		if err := processFile("<standard input>", stdin, stdout, opts); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	code := exitOK
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Named files are formatted whatever their extension.
+			if d.IsDir() || (name != path && filepath.Ext(name) != ".conf") {
+				return nil
+			}
+			if err := processFile(name, nil, stdout, opts); err != nil {
+				fmt.Fprintln(stderr, err)
+				code = exitError
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+// processFile formats the named file, read from in if set, and reports the
+// result as requested by opts.
+func processFile(name string, in io.Reader, stdout io.Writer, opts options) error {
+	var src []byte
+	var err error
+	if in != nil {
+		src, err = io.ReadAll(in)
+	} else {
+		src, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := config.Format(src, name)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(src, res) {
+		if !opts.list && !opts.diff && !opts.write {
+			_, err = stdout.Write(res)
+		}
+		return err
+	}
+
+	if opts.list {
+		fmt.Fprintln(stdout, name)
+	}
+	if opts.write {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if opts.diff {
+		fmt.Fprintf(stdout, "diff %s.orig %s\n", name, name)
+		_, err = io.WriteString(stdout, unifiedDiff(name+".orig", name, string(src), string(res)))
+		return err
+	}
+	if !opts.list && !opts.write {
+		_, err = stdout.Write(res)
+	}
+	return err
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffLine is a line of a diff, prefixed with ' ', '-' or '+'.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the changes from a to b in unified format.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+	// lineA and lineB are the line numbers in a and b before lines[i]
+	lineA, lineB := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+
+		// Extend the hunk as long as changes are close enough to share context.
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(lines))
+
+		startA, startB := lineA-(i-start), lineB-(i-start)
+		countA, countB := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				countA++
+			}
+			if l.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+
+		for _, l := range lines[i:end] {
+			if l.op != '+' {
+				lineA++
+			}
+			if l.op != '-' {
+				lineB++
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of a hunk, where start is the number of lines before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s into lines without their line breaks.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns an edit script from a to b based on their longest common subsequence.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var res []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			res = append(res, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			res = append(res, diffLine{'-', a[i]})
+			i++
+		default:
+			res = append(res, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const unformatted = "a   1\nb {\n c 2\n}\n"
+	const formatted = "a 1\nb {\n    c 2\n}\n"
+
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		files := map[string]string{
+			"unformatted.conf":    unformatted,
+			"formatted.conf":      formatted,
+			"sub/nested.conf":     unformatted,
+			"sub/ignored.txt":     unformatted,
+			"broken/invalid.conf": "a {\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+		wantFiles  map[string]string
+	}{
+		{name: "stdin", stdin: unformatted, wantStdout: formatted},
+		{name: "stdin error", stdin: "}\n", wantCode: exitError, wantStderr: "<standard input>:1: unexpected \"}\" outside of a block"},
+		{name: "file", args: []string{"unformatted.conf"}, wantStdout: formatted},
+		{name: "list", args: []string{"-l", "unformatted.conf", "formatted.conf", "sub"}, wantStdout: "unformatted.conf\nsub/nested.conf\n"},
+		{name: "diff", args: []string{"-d", "unformatted.conf", "formatted.conf"},
+			wantStdout: "diff unformatted.conf.orig unformatted.conf\n--- unformatted.conf.orig\n+++ unformatted.conf\n" +
+				"@@ -1,4 +1,4 @@\n-a   1\n+a 1\n b {\n- c 2\n+    c 2\n }\n"},
+		{name: "write", args: []string{"-w", "."}, wantCode: exitError, wantStderr: "broken/invalid.conf:1: block is not closed",
+			wantFiles: map[string]string{"unformatted.conf": formatted, "sub/nested.conf": formatted, "sub/ignored.txt": unformatted}},
+		{name: "named file with other extension", args: []string{"-l", "sub/ignored.txt"}, wantStdout: "sub/ignored.txt\n"},
+		{name: "missing file", args: []string{"missing.conf"}, wantCode: exitError, wantStderr: "missing.conf"},
+		{name: "write stdin", args: []string{"-w"}, wantCode: exitError, wantStderr: "cannot use -w"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setup(t)
+			chdir(t, dir)
+
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Errorf("run() = %d, want %d, stderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("run() stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	b := "1\nx\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\ny\n"
+	want := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+		"@@ -14,3 +14,4 @@\n 14\n 15\n 16\n+y\n"
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// chdir changes the working directory to dir for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
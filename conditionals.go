@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strconv"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// conditionState tracks a chain of if, else if and else blocks.
+type conditionState int
+
+const (
+	// noCondition means that the previous node doesn't belong to a chain
+	noCondition conditionState = iota
+	// conditionPending means that no block of the chain has been selected yet
+	conditionPending
+	// conditionSelected means that a block of the chain has been selected
+	conditionSelected
+)
+
+// isConditional reports whether node is an if or else block.
+// if and else directives without a block are regular nodes.
+func isConditional(node parser.Node) bool {
+	return node.Children != nil && (node.Name == "if" || node.Name == "else")
+}
+
+// selectBranch reports whether the block of the conditional node is selected,
+// given the state of its chain, and advances the state. The condition of a block
+// is only evaluated if no earlier block of the chain has been selected.
+func (l *loader) selectBranch(node parser.Node, chain *conditionState) (bool, error) {
+	condition := node.Args
+	if node.Name == "else" {
+		if *chain == noCondition {
+			return false, nodes.NodeErr(node, "else without preceding if")
+		}
+		if len(condition) == 0 {
+			selected := *chain == conditionPending
+			*chain = noCondition
+			return selected, nil
+		}
+		if condition[0] != "if" {
+			return false, nodes.NodeErr(node, "unexpected arguments after else, expected a block or 'else if'")
+		}
+		condition = condition[1:]
+		if *chain == conditionSelected {
+			return false, nil
+		}
+	}
+
+	node.Args = condition
+	args, err := l.expandArgs(node)
+	if err != nil {
+		return false, err
+	}
+	selected, err := evalCondition(node, args)
+	if err != nil {
+		return false, err
+	}
+
+	*chain = conditionPending
+	if selected {
+		*chain = conditionSelected
+	}
+	return selected, nil
+}
+
+// evalCondition evaluates the expanded condition args of node. A condition is
+// either a single boolean value, where an empty value is false, or a comparison
+// of two values with == or !=.
+func evalCondition(node parser.Node, args []string) (bool, error) {
+	switch {
+	case len(args) == 1 && args[0] == "":
+		return false, nil
+	case len(args) == 1:
+		value, err := strconv.ParseBool(args[0])
+		if err != nil {
+			return false, nodes.NodeErr(node, "invalid condition value %q, expected a boolean", args[0])
+		}
+		return value, nil
+	case len(args) == 3 && args[1] == "==":
+		return args[0] == args[2], nil
+	case len(args) == 3 && args[1] == "!=":
+		return args[0] != args[2], nil
+	}
+	return false, nodes.NodeErr(node, "invalid condition, expected 'value', 'a == b' or 'a != b'")
+}
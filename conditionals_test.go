@@ -0,0 +1,166 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadConditionals(t *testing.T) {
+	env := WithEnvMap(map[string]string{"APP_ENV": "production", "DEBUG": "true"})
+
+	tests := []struct {
+		name    string
+		content string
+		options []ReadOption
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "selected",
+			content: "a\nif {env:APP_ENV} == production {\n    b\n}\nc",
+			want:    "a\nb\nc\n",
+		},
+		{
+			name:    "not selected",
+			content: "a\nif {env:APP_ENV} != production {\n    b\n}\nc",
+			want:    "a\nc\n",
+		},
+		{
+			name:    "else",
+			content: "if {env:APP_ENV} == staging {\n    a\n}\nelse {\n    b\n}",
+			want:    "b\n",
+		},
+		{
+			name:    "else if chain",
+			content: "if {env:APP_ENV} == dev {\n    a\n}\nelse if {env:APP_ENV} == production {\n    b\n}\nelse if {env:DEBUG} {\n    c\n}\nelse {\n    d\n}",
+			want:    "b\n",
+		},
+		{
+			name:    "boolean",
+			content: "if {env:DEBUG} {\n    log_level debug\n}\nif {env:UNDEFINED} {\n    never\n}",
+			want:    "log_level debug\n",
+		},
+		{
+			name:    "nested in block",
+			content: "server {\n    listen 80\n    if {env:APP_ENV} == production {\n        tls on\n        if {env:DEBUG} {\n            trace\n        }\n    }\n}",
+			want:    "server {\n    listen 80\n    tls on\n    trace\n}\n",
+		},
+		{
+			name:    "macros and snippets",
+			content: "$(mode) = production\n(prod) {\n    replicas 3\n}\nif $(mode) == production {\n    import prod\n}",
+			want:    "replicas 3\n",
+		},
+		{
+			name:    "consecutive chains",
+			content: "if true {\n    a\n}\nif false {\n    b\n}\nelse {\n    c\n}",
+			want:    "a\nc\n",
+		},
+		{
+			name:    "directive named if",
+			content: "if a b",
+			want:    "if a b\n",
+		},
+		{
+			name:    "skipped branches are not expanded",
+			content: "if {env:APP_ENV} == production {\n    a\n}\nelse if {env:MISSING} {\n    b {env:MISSING}\n}",
+			options: []ReadOption{WithStrictEnv()},
+			want:    "a\n",
+		},
+		{
+			name:    "imports in skipped branches are not resolved",
+			content: "(prod) {\n    replicas 3\n}\nif {env:APP_ENV} != production {\n    import dev_only.conf\n}\nelse {\n    import prod\n}",
+			want:    "replicas 3\n",
+		},
+		{
+			name:    "else without if",
+			content: "a\nelse {\n    b\n}",
+			wantErr: "test.conf:2: else without preceding if",
+		},
+		{
+			name:    "else after directive",
+			content: "if true {\n    a\n}\nb\nelse {\n    c\n}",
+			wantErr: "test.conf:5: else without preceding if",
+		},
+		{
+			name:    "invalid else",
+			content: "if true {\n    a\n}\nelse true {\n    c\n}",
+			wantErr: "test.conf:4: unexpected arguments after else, expected a block or 'else if'",
+		},
+		{
+			name:    "invalid value",
+			content: "if maybe {\n    a\n}",
+			wantErr: `test.conf:1: invalid condition value "maybe", expected a boolean`,
+		},
+		{
+			name:    "invalid operator",
+			content: "if a < b {\n    a\n}",
+			wantErr: "test.conf:1: invalid condition, expected 'value', 'a == b' or 'a != b'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf", append([]ReadOption{env}, tt.options...)...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			got, err := Marshal(ast)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Read() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConditionalImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":    {Data: []byte("if {env:APP_ENV} == production {\n    import prod_only.conf\n}\nimport tls.conf {env:APP_ENV}\n")},
+		"tls.conf":     {Data: []byte("if {args[0]} == production {\n    import certs\n}\nelse {\n    tls self_signed\n}\n")},
+		"certs.conf":   {Data: []byte("tls /etc/tls/cert.pem\n")},
+		"staging.conf": {Data: []byte("import tls.conf staging\n")},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		want    string
+		wantErr string
+	}{
+		{name: "skipped import", file: "main.conf", env: "dev", want: "tls self_signed\n"},
+		{name: "selected import", file: "main.conf", env: "production", wantErr: "main.conf:2: unknown import prod_only.conf, there is no snippet or file of this name"},
+		{name: "import arguments", file: "staging.conf", want: "tls self_signed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := ReadFS(fsys, tt.file, WithEnvMap(map[string]string{"APP_ENV": tt.env}))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ReadFS() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFS() error = %v", err)
+			}
+			got, err := Marshal(ast)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ReadFS() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"io"
+	"io/fs"
 	"os"
 
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
@@ -11,18 +12,32 @@ import (
 // AST represents the Abstract Syntax Tree of a configuration file
 type AST []parser.Node
 
-// Read parses configuration from an io.Reader and returns the AST
-func Read(r io.Reader, location string) (AST, error) {
-	return parser.Read(r, location)
+// Read parses configuration from an io.Reader and returns the AST.
+// Imported files are resolved relative to location.
+func Read(r io.Reader, location string, options ...ReadOption) (AST, error) {
+	return newLoader(osFS{}, options...).read(r, location)
 }
 
 // ReadFile reads and parses configuration from a file and returns the AST
-func ReadFile(filename string) (AST, error) {
+func ReadFile(filename string, options ...ReadOption) (AST, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	return parser.Read(f, filename)
+	defer f.Close()
+	return Read(f, filename, options...)
+}
+
+// ReadFS reads and parses the named configuration file from fsys and returns the AST.
+// Imports are resolved within fsys as well: relative names against the directory of
+// the importing file, absolute names against the root of fsys.
+func ReadFS(fsys fs.FS, name string, options ...ReadOption) (AST, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return newLoader(ioFS{fsys}, options...).read(f, name)
 }
 
 // ExpectMaxArgN checks if a configuration node has at most the specified number of arguments
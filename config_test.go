@@ -1,9 +1,11 @@
 package config
 
 import (
+	"embed"
 	"os"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
 )
@@ -361,4 +363,127 @@ func TestASTConversion(t *testing.T) {
 	if len(nodes[1].Children) != 1 {
 		t.Errorf("Expected second node to have 1 child, got %d", len(nodes[1].Children))
 	}
-}
\ No newline at end of file
+}
+
+func TestReadEscapes(t *testing.T) {
+	t.Setenv("TEST_ESCAPE_VAR", "expanded")
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "escaped environment placeholder",
+			content: `d \{env:TEST_ESCAPE_VAR} {env:TEST_ESCAPE_VAR}`,
+			want:    []string{"{env:TEST_ESCAPE_VAR}", "expanded"},
+		},
+		{
+			name:    "escaped macro reference",
+			content: "$(m) = value\nd \\$(m) $(m) x\\$(m)",
+			want:    []string{"$(m)", "value", "x$(m)"},
+		},
+		{
+			name:    "lone escaped braces",
+			content: `d \{ \}`,
+			want:    []string{"{", "}"},
+		},
+		{
+			name:    "backslashes elsewhere are literal",
+			content: `d C:\dir \{2,3\} "\{env:TEST_ESCAPE_VAR}"`,
+			want:    []string{`C:\dir`, `\{2,3\}`, "{env:TEST_ESCAPE_VAR}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(ast) != 1 {
+				t.Fatalf("Read() returned %d nodes, want 1", len(ast))
+			}
+			if strings.Join(ast[0].Args, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Read() args = %q, want %q", ast[0].Args, tt.want)
+			}
+		})
+	}
+}
+
+//go:embed testdata/*.conf
+var testdataFS embed.FS
+
+func TestReadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf":           {Data: []byte("import conf.d/listen\nimport /common.conf\nlog_level info")},
+		"conf.d/listen.conf": {Data: []byte("import ../tls.conf\nlisten 25")},
+		"tls.conf":           {Data: []byte("tls on")},
+		"common.conf":        {Data: []byte("(snip) {\n    timeout 30\n}\nhostname mx")},
+		"snippet.conf":       {Data: []byte("import common\nimport snip")},
+		"missing.conf":       {Data: []byte("import nowhere.conf")},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "relative and absolute imports",
+			file: "app.conf",
+			want: []string{"tls", "listen", "hostname", "log_level"},
+		},
+		{
+			name: "snippet from imported file",
+			file: "snippet.conf",
+			want: []string{"hostname", "timeout"},
+		},
+		{
+			name:    "unknown import",
+			file:    "missing.conf",
+			wantErr: true,
+		},
+		{
+			name:    "non-existent file",
+			file:    "nonexistent.conf",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := ReadFS(fsys, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for _, node := range ast {
+				names = append(names, node.Name)
+			}
+			if strings.Join(names, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ReadFS() returned nodes %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFSEmbed(t *testing.T) {
+	ast, err := ReadFS(testdataFS, "testdata/with_imports.conf")
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+
+	// base_setting and base_timeout from the imported file, then server main
+	if len(ast) != 3 {
+		t.Fatalf("ReadFS() returned %d nodes, want 3", len(ast))
+	}
+	if ast[0].Name != "base_setting" || ast[0].File != "testdata/import_base.conf" {
+		t.Errorf("Expected base_setting from testdata/import_base.conf, got %s from %s", ast[0].Name, ast[0].File)
+	}
+}
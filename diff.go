@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// ChangeKind is the kind of a change between two configuration trees.
+type ChangeKind int
+
+const (
+	// Added means that the node only exists in the new tree
+	Added ChangeKind = iota
+	// Removed means that the node only exists in the old tree
+	Removed
+	// Changed means that the arguments of a directive changed
+	Changed
+)
+
+// String returns the name of the kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// MarshalText encodes the kind as its name.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is a difference between two configuration trees.
+type Change struct {
+	// Kind is the kind of the change
+	Kind ChangeKind
+	// Path is the path of the node, e.g. "server[web]/listen". Blocks are identified
+	// by their name and arguments, directives by their name.
+	Path string
+	// Old is the node in the old tree, nil for added nodes
+	Old *parser.Node
+	// New is the node in the new tree, nil for removed nodes
+	New *parser.Node
+}
+
+// String formats the change as a single line, e.g. "~ log_level: info -> debug".
+// Added and removed blocks are abbreviated.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return "+ " + c.Path + formatDiffNode(c.New)
+	case Removed:
+		return "- " + c.Path + formatDiffNode(c.Old)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, formatDiffArgs(c.Old.Args), formatDiffArgs(c.New.Args))
+}
+
+// MarshalJSON encodes the change as an object with the kind, the path and the
+// old and new node in the JSON form of AST.
+func (c Change) MarshalJSON() ([]byte, error) {
+	type jsonChange struct {
+		Kind ChangeKind `json:"kind"`
+		Path string     `json:"path"`
+		Old  *jsonNode  `json:"old,omitempty"`
+		New  *jsonNode  `json:"new,omitempty"`
+	}
+	toJSON := func(node *parser.Node) *jsonNode {
+		if node == nil {
+			return nil
+		}
+		return &toJSONNodes([]parser.Node{*node})[0]
+	}
+	return json.Marshal(jsonChange{Kind: c.Kind, Path: c.Path, Old: toJSON(c.Old), New: toJSON(c.New)})
+}
+
+// FormatDiff formats changes as text, one change per line.
+func FormatDiff(changes []Change) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(c.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Diff compares two configuration trees. Blocks are matched by their name and
+// arguments and compared recursively; a block whose arguments changed is reported
+// as removed and added. Directives are matched by their name; a directive that only
+// occurs in one tree is reported as added or removed, one with other arguments as
+// changed. Repeated directives with the same arguments in both trees are unchanged.
+// Node positions are not compared.
+func Diff(from, to AST) []Change {
+	return diffNodes(from, to, "")
+}
+
+// diffNodes compares the old and new nodes of the blocks at path.
+func diffNodes(old, updated []parser.Node, path string) []Change {
+	var changes, removed []Change
+	matched := make([]bool, len(old))
+
+	// Identical directives match first, so that the remaining ones can be paired up in order.
+	newMatched := make([]bool, len(updated))
+	for j, n := range updated {
+		if n.Children != nil {
+			continue
+		}
+		i := indexUnmatched(old, matched, func(o parser.Node) bool {
+			return o.Children == nil && o.Name == n.Name && slices.Equal(o.Args, n.Args)
+		})
+		if i != -1 {
+			matched[i] = true
+			newMatched[j] = true
+		}
+	}
+
+	for j := range updated {
+		if newMatched[j] {
+			continue
+		}
+		n := &updated[j]
+		nodePath := nodes.JoinPath(path, *n)
+
+		if n.Children == nil {
+			i := indexUnmatched(old, matched, func(o parser.Node) bool {
+				return o.Children == nil && o.Name == n.Name
+			})
+			if i == -1 {
+				changes = append(changes, Change{Kind: Added, Path: nodePath, New: n})
+				continue
+			}
+			matched[i] = true
+			changes = append(changes, Change{Kind: Changed, Path: nodePath, Old: &old[i], New: n})
+			continue
+		}
+
+		i := indexUnmatched(old, matched, func(o parser.Node) bool {
+			return o.Children != nil && o.Name == n.Name && slices.Equal(o.Args, n.Args)
+		})
+		if i == -1 {
+			changes = append(changes, Change{Kind: Added, Path: nodePath, New: n})
+			continue
+		}
+		matched[i] = true
+		changes = append(changes, diffNodes(old[i].Children, n.Children, nodePath)...)
+	}
+
+	for i := range old {
+		if !matched[i] {
+			removed = append(removed, Change{Kind: Removed, Path: nodes.JoinPath(path, old[i]), Old: &old[i]})
+		}
+	}
+	return append(removed, changes...)
+}
+
+// indexUnmatched returns the index of the first node in list that isn't matched yet
+// and satisfies f, or -1.
+func indexUnmatched(list []parser.Node, matched []bool, f func(parser.Node) bool) int {
+	for i, node := range list {
+		if !matched[i] && f(node) {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatDiffNode formats the arguments of an added or removed directive, or
+// abbreviates the contents of a block.
+func formatDiffNode(node *parser.Node) string {
+	if node.Children != nil {
+		return " {...}"
+	}
+	if len(node.Args) == 0 {
+		return ""
+	}
+	return " " + formatDiffArgs(node.Args)
+}
+
+// formatDiffArgs formats arguments as they would be written in a configuration file.
+func formatDiffArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		q, err := quoteArg(arg)
+		if err != nil {
+			q = fmt.Sprintf("%q", arg)
+		}
+		quoted = append(quoted, q)
+	}
+	if len(quoted) == 0 {
+		return "(no arguments)"
+	}
+	return strings.Join(quoted, " ")
+}
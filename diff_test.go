@@ -0,0 +1,131 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := `log_level info
+hostname mx.example.org
+listen 25
+listen 587
+server web {
+    root /srv/www
+    tls {
+        cert old.pem
+    }
+}
+server legacy {
+    root /srv/legacy
+}
+`
+
+	tests := []struct {
+		name string
+		new  string
+		want string
+	}{
+		{
+			name: "unchanged",
+			new:  old,
+			want: "",
+		},
+		{
+			name: "changed directive",
+			new:  strings.Replace(old, "log_level info", "log_level debug", 1),
+			want: "~ log_level: info -> debug\n",
+		},
+		{
+			name: "nested change",
+			new:  strings.Replace(old, "cert old.pem", "cert new.pem", 1),
+			want: "~ server[web]/tls/cert: old.pem -> new.pem\n",
+		},
+		{
+			name: "added and removed directives",
+			new:  strings.Replace(old, "hostname mx.example.org\n", "timeout 30s\n", 1),
+			want: "- hostname mx.example.org\n+ timeout 30s\n",
+		},
+		{
+			name: "repeated directives",
+			new:  strings.Replace(old, "listen 25\nlisten 587\n", "listen 587\nlisten 465\nlisten 2525\n", 1),
+			want: "~ listen: 25 -> 465\n+ listen 2525\n",
+		},
+		{
+			name: "block arguments",
+			new:  strings.Replace(old, "server legacy", "server api", 1),
+			want: "- server[legacy] {...}\n+ server[api] {...}\n",
+		},
+		{
+			name: "reordered",
+			new:  "server legacy {\n    root /srv/legacy\n}\n" + strings.Replace(old, "server legacy {\n    root /srv/legacy\n}\n", "", 1),
+			want: "",
+		},
+		{
+			name: "quoted arguments",
+			new:  strings.Replace(old, "root /srv/www", `root "/srv/my www"`, 1),
+			want: "~ server[web]/root: /srv/www -> \"/srv/my www\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := Read(strings.NewReader(old), "old.conf")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			to, err := Read(strings.NewReader(tt.new), "new.conf")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			if got := FormatDiff(Diff(from, to)); got != tt.want {
+				t.Errorf("Diff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+	from := AST{{Name: "log_level", Args: []string{"info"}, File: "old.conf", Line: 1}}
+	to := AST{{Name: "log_level", Args: []string{"debug"}, File: "new.conf", Line: 3}}
+
+	changes := Diff(from, to)
+	if len(changes) != 1 {
+		t.Fatalf("Diff() returned %d changes, want 1", len(changes))
+	}
+	c := changes[0]
+	if c.Kind != Changed || c.Path != "log_level" || c.Old.Line != 1 || c.New.File != "new.conf" || c.New.Line != 3 {
+		t.Errorf("Diff() = %+v", c)
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `[{"kind":"changed","path":"log_level",` +
+		`"old":{"name":"log_level","args":["info"],"file":"old.conf","line":1},` +
+		`"new":{"name":"log_level","args":["debug"],"file":"new.conf","line":3}}]`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	data, err = json.Marshal(Diff(nil, to))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `[{"kind":"added","path":"log_level","new":{"name":"log_level","args":["debug"],"file":"new.conf","line":3}}]`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	secrets := Secrets{values: map[string]bool{"diff-secret": true}}
+	from := AST{{Name: "password", Args: []string{"diff-secret"}}}
+	to := AST{{Name: "password", Args: []string{"other"}}}
+
+	if got, want := FormatDiff(Diff(secrets.Redact(from), to)), "~ password: "+Redacted+" -> other\n"; got != want {
+		t.Errorf("FormatDiff() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNode is the JSON and YAML representation of a configuration node:
+//
+//	{
+//	  "name": "server",
+//	  "args": ["web"],
+//	  "children": [{"name": "listen", "args": ["80"], "file": "app.conf", "line": 2}],
+//	  "file": "app.conf",
+//	  "line": 1
+//	}
+//
+// Only name is required. A missing children list denotes a directive, an empty
+// one an empty block. The snippet and macro flags mirror the parser.Node fields.
+type jsonNode struct {
+	Name     string      `json:"name" yaml:"name"`
+	Args     []string    `json:"args,omitempty" yaml:"args,omitempty"`
+	Children *[]jsonNode `json:"children,omitempty" yaml:"children,omitempty"`
+	Snippet  bool        `json:"snippet,omitempty" yaml:"snippet,omitempty"`
+	Macro    bool        `json:"macro,omitempty" yaml:"macro,omitempty"`
+	File     string      `json:"file,omitempty" yaml:"file,omitempty"`
+	Line     int         `json:"line,omitempty" yaml:"line,omitempty"`
+}
+
+// MarshalJSON encodes the tree as a JSON array of nodes.
+func (a AST) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toJSONNodes(a))
+}
+
+// UnmarshalJSON decodes a JSON array of nodes, as produced by MarshalJSON.
+func (a *AST) UnmarshalJSON(data []byte) error {
+	var list []jsonNode
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	return a.fromJSONNodes(list)
+}
+
+// MarshalYAML encodes the tree as a YAML sequence of nodes, using the same
+// fields as the JSON form.
+func (a AST) MarshalYAML() (interface{}, error) {
+	return toJSONNodes(a), nil
+}
+
+// UnmarshalYAML decodes a YAML sequence of nodes, as produced by MarshalYAML.
+func (a *AST) UnmarshalYAML(value *yaml.Node) error {
+	var list []jsonNode
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	return a.fromJSONNodes(list)
+}
+
+// ReadJSON reads a configuration tree in its JSON form.
+// The result can be passed to EvaluateTree like the result of Read.
+func ReadJSON(r io.Reader) (AST, error) {
+	var ast AST
+	if err := json.NewDecoder(r).Decode(&ast); err != nil {
+		return nil, err
+	}
+	return ast, nil
+}
+
+// ReadYAML reads a configuration tree in its YAML form.
+// The result can be passed to EvaluateTree like the result of Read.
+func ReadYAML(r io.Reader) (AST, error) {
+	var ast AST
+	if err := yaml.NewDecoder(r).Decode(&ast); err != nil {
+		return nil, err
+	}
+	return ast, nil
+}
+
+func (a *AST) fromJSONNodes(list []jsonNode) error {
+	nodes, err := fromJSONNodes(list, "")
+	if err != nil {
+		return err
+	}
+	*a = nodes
+	return nil
+}
+
+func toJSONNodes(list []parser.Node) []jsonNode {
+	res := make([]jsonNode, 0, len(list))
+	for _, node := range list {
+		n := jsonNode{
+			Name:    node.Name,
+			Args:    node.Args,
+			Snippet: node.Snippet,
+			Macro:   node.Macro,
+			File:    node.File,
+			Line:    node.Line,
+		}
+		if node.Children != nil {
+			children := toJSONNodes(node.Children)
+			n.Children = &children
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// fromJSONNodes converts decoded nodes, validating their names.
+// path is the JSON pointer of the list, used in error messages.
+func fromJSONNodes(list []jsonNode, path string) ([]parser.Node, error) {
+	res := make([]parser.Node, 0, len(list))
+	for i, n := range list {
+		nodePath := fmt.Sprintf("%s/%d", path, i)
+		if !n.Snippet && !n.Macro {
+			if err := checkNodeName(n.Name); err != nil {
+				return nil, fmt.Errorf("%s: %v", nodePath, err)
+			}
+		}
+
+		node := parser.Node{
+			Name:    n.Name,
+			Args:    n.Args,
+			Snippet: n.Snippet,
+			Macro:   n.Macro,
+			File:    n.File,
+			Line:    n.Line,
+		}
+		// Match the parser, which never returns nil arguments.
+		if node.Args == nil {
+			node.Args = []string{}
+		}
+		if n.Children != nil {
+			children, err := fromJSONNodes(*n.Children, nodePath+"/children")
+			if err != nil {
+				return nil, err
+			}
+			node.Children = children
+		}
+		res = append(res, node)
+	}
+	return res, nil
+}
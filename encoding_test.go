@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"gopkg.in/yaml.v3"
+)
+
+func TestASTMarshalJSON(t *testing.T) {
+	ast := AST{
+		{
+			Name: "server",
+			Args: []string{"web"},
+			Children: []parser.Node{
+				{Name: "listen", Args: []string{"80"}, File: "app.conf", Line: 2},
+				{Name: "empty", Args: []string{}, Children: []parser.Node{}, File: "app.conf", Line: 3},
+			},
+			File: "app.conf",
+			Line: 1,
+		},
+	}
+
+	data, err := json.Marshal(ast)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	want := `[{"name":"server","args":["web"],"children":[` +
+		`{"name":"listen","args":["80"],"file":"app.conf","line":2},` +
+		`{"name":"empty","children":[],"file":"app.conf","line":3}` +
+		`],"file":"app.conf","line":1}]`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    AST
+		wantErr bool
+	}{
+		{
+			name:    "directive and block",
+			content: `[{"name": "log_level", "args": ["info"]}, {"name": "server", "children": [{"name": "listen", "args": ["80"], "line": 3}]}]`,
+			want: AST{
+				{Name: "log_level", Args: []string{"info"}},
+				{Name: "server", Args: []string{}, Children: []parser.Node{
+					{Name: "listen", Args: []string{"80"}, Line: 3},
+				}},
+			},
+		},
+		{
+			name:    "empty block",
+			content: `[{"name": "server", "children": []}]`,
+			want:    AST{{Name: "server", Args: []string{}, Children: []parser.Node{}}},
+		},
+		{
+			name:    "empty tree",
+			content: `[]`,
+			want:    AST{},
+		},
+		{
+			name:    "missing name",
+			content: `[{"name": "server", "children": [{"args": ["80"]}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			content: `[{"name": "bad name"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed JSON",
+			content: `[{"name": }]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadJSON(strings.NewReader(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJSONErrorPath(t *testing.T) {
+	_, err := ReadJSON(strings.NewReader(`[{"name": "a"}, {"name": "b", "children": [{"name": "1x"}]}]`))
+	if err == nil {
+		t.Fatal("ReadJSON() expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "/1/children/0") {
+		t.Errorf("ReadJSON() error should point at the invalid node, got: %v", err)
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	ast, err := ReadFile("testdata/simple.conf")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	ast = append(ast, parser.Node{Name: "empty", Args: []string{}, Children: []parser.Node{}})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(ast)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		got, err := ReadJSON(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, ast) {
+			t.Errorf("JSON round trip mismatch\ngot:  %+v\nwant: %+v", got, ast)
+		}
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		data, err := yaml.Marshal(ast)
+		if err != nil {
+			t.Fatalf("yaml.Marshal() error = %v", err)
+		}
+		got, err := ReadYAML(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("ReadYAML() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, ast) {
+			t.Errorf("YAML round trip mismatch\n%s\ngot:  %+v\nwant: %+v", data, got, ast)
+		}
+	})
+}
+
+func TestReadYAML(t *testing.T) {
+	content := `
+- name: log_level
+  args: [info]
+- name: server
+  args: [web]
+  children:
+    - name: listen
+      args: ["80"]
+`
+	got, err := ReadYAML(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ReadYAML() error = %v", err)
+	}
+
+	want := AST{
+		{Name: "log_level", Args: []string{"info"}},
+		{Name: "server", Args: []string{"web"}, Children: []parser.Node{
+			{Name: "listen", Args: []string{"80"}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadYAML() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// Format reformats configuration source in the canonical style of Marshal while
+// keeping its comments. Imports, snippets, macros and placeholders are left as
+// they are; only the layout changes:
+//
+//   - each nesting level is indented by four spaces
+//   - opening braces end the line of their node, closing braces are on their own line
+//   - tokens are separated by a single space, comments by a space from the tokens before them
+//   - quotes are removed from arguments that don't need them
+//   - runs of blank lines are reduced to one, blank lines at the start and end of
+//     blocks and of the file are removed
+//
+// Lines continued with a trailing backslash stay continued, indented one more level.
+// location is used in errors, which are returned for unbalanced braces.
+func Format(src []byte, location string) ([]byte, error) {
+	f := formatter{location: location, opened: true}
+	lines, err := lexLines(src, location)
+	for _, line := range lines {
+		if err := f.line(line); err != nil {
+			return nil, err
+		}
+	}
+	// The lines end before an unterminated quoted string.
+	if err != nil {
+		return nil, err
+	}
+	if f.depth != 0 {
+		return nil, f.errorf(f.openLines[f.depth-1], `block is not closed, "}" expected before the end of the file`)
+	}
+	return f.buf.Bytes(), nil
+}
+
+// formatter writes the formatted lines.
+type formatter struct {
+	location string
+	buf      bytes.Buffer
+	depth    int
+	// openLines are the lines of the opening braces of the enclosing blocks
+	openLines []int
+	// continued reports whether the previous line ended with a backslash
+	continued bool
+	// pendingBlank reports whether a blank line is to be written before the next line
+	pendingBlank bool
+	// opened reports whether nothing has been written since the start of the file or a block
+	opened   bool
+	lastLine int
+}
+
+// line formats a source line, which can hold the end of one block and the start
+// of another, as in "a { b }".
+func (f *formatter) line(line sourceLine) error {
+	if line.blank && !f.continued {
+		f.pendingBlank = true
+	}
+	f.lastLine = line.line
+
+	var words []string
+	inNode := f.continued
+	indent := f.depth
+	if f.continued {
+		indent++
+	}
+	f.continued = false
+
+	for i, token := range line.tokens {
+		last := i == len(line.tokens)-1
+		switch {
+		case token.value == "{" && !inNode:
+			return f.errorf(token.line, `a block needs a name before "{"`)
+		case token.value == "{":
+			comment := ""
+			if last {
+				comment = line.comment
+			}
+			f.write(indent, strings.Join(append(words, "{"), " "), comment)
+			f.depth++
+			f.openLines = append(f.openLines[:f.depth-1], token.line)
+			f.opened = true
+			words, inNode, indent = nil, false, f.depth
+		case token.value == "}" && (!inNode || last):
+			if f.depth == 0 {
+				return f.errorf(token.line, `unexpected "}" outside of a block`)
+			}
+			if !last {
+				return f.errorf(token.line, `unexpected %s after "}", a closing brace must end its line`, line.tokens[i+1].value)
+			}
+			if inNode {
+				f.write(indent, strings.Join(words, " "), "")
+			}
+			f.depth--
+			f.pendingBlank = false
+			f.write(f.depth, "}", line.comment)
+			return nil
+		default:
+			words = append(words, formatTokenText(token))
+			inNode = true
+		}
+	}
+
+	switch {
+	case len(words) != 0:
+		f.write(indent, strings.Join(words, " "), line.comment)
+		f.continued = words[len(words)-1] == `\`
+	case line.comment != "" && (len(line.tokens) == 0 || line.tokens[len(line.tokens)-1].value != "{"):
+		f.write(indent, "", line.comment)
+		f.continued = inNode
+	}
+	return nil
+}
+
+// write writes a line of text at the given depth, followed by comment if set.
+func (f *formatter) write(depth int, text, comment string) {
+	if f.pendingBlank && !f.opened {
+		f.buf.WriteByte('\n')
+	}
+	f.pendingBlank = false
+	f.opened = false
+
+	f.buf.WriteString(strings.Repeat(indentUnit, depth))
+	f.buf.WriteString(text)
+	if comment != "" {
+		if text != "" {
+			f.buf.WriteByte(' ')
+		}
+		f.buf.WriteString(comment)
+	}
+	f.buf.WriteByte('\n')
+}
+
+func (f *formatter) errorf(line int, format string, args ...any) error {
+	return nodes.NodeErr(parser.Node{File: f.location, Line: line}, format, args...)
+}
+
+// formatTokenText returns the canonical form of a token. Quotes are only kept
+// where the token couldn't be read back the same without them.
+func formatTokenText(token sourceToken) string {
+	if !token.quoted {
+		return token.text
+	}
+	v := token.value
+	if v == "" || v == `\` || strings.HasPrefix(v, `"`) || strings.ContainsRune(v, '#') ||
+		strings.IndexFunc(v, unicode.IsSpace) >= 0 {
+		return token.text
+	}
+	return v
+}
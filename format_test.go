@@ -0,0 +1,126 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "indentation and spacing",
+			input: "log_level   info\nserver web {\n\tlisten :80\n  tls {\n        cert_file  cert.pem\n  }\n}\n",
+			want:  "log_level info\nserver web {\n    listen :80\n    tls {\n        cert_file cert.pem\n    }\n}\n",
+		},
+		{
+			name:  "comments",
+			input: "# header\nserver web { # web server\n  listen :80   # http\n    # disabled:\n  # root /srv\n}  # end\n",
+			want:  "# header\nserver web { # web server\n    listen :80 # http\n    # disabled:\n    # root /srv\n} # end\n",
+		},
+		{
+			name:  "comment within a token",
+			input: "a b#c\n",
+			want:  "a b #c\n",
+		},
+		{
+			name:  "inline blocks",
+			input: "server web { listen :80 }\nempty { }\ntls {\n  a 1 }\n",
+			want:  "server web {\n    listen :80\n}\nempty {\n}\ntls {\n    a 1\n}\n",
+		},
+		{
+			name:  "blank lines",
+			input: "\n\na 1\n\n\n\nb 2\nc {\n\n  d 3\n\n\n  e 4\n\n}\n\n\n",
+			want:  "a 1\n\nb 2\nc {\n    d 3\n\n    e 4\n}\n",
+		},
+		{
+			name:  "quoting",
+			input: `a "b" "c d" "" "e#f" "\"g" "h\"i" "{env:X}" "\\" "{x"` + "\n",
+			want:  `a b "c d" "" "e#f" "\"g" h"i {env:X} \\ {x` + "\n",
+		},
+		{
+			name:  "continued lines",
+			input: "allow 10.0.0.1 \\\n10.0.0.2 \\\n  # comment\n   10.0.0.3\nb {\nc 1 \\\n 2 }\n",
+			want:  "allow 10.0.0.1 \\\n    10.0.0.2 \\\n    # comment\n    10.0.0.3\nb {\n    c 1 \\\n        2\n}\n",
+		},
+		{
+			name:  "quoted token spanning lines",
+			input: "motd \"line one\nline two\"   x\n",
+			want:  "motd \"line one\nline two\" x\n",
+		},
+		{
+			name:  "snippets and macros",
+			input: "$(hosts)   =  a.example b.example\n(common) {\nlog on\n}\nsite $(hosts) {\nimport   common\n}\n",
+			want:  "$(hosts) = a.example b.example\n(common) {\n    log on\n}\nsite $(hosts) {\n    import common\n}\n",
+		},
+		{
+			name:  "byte order mark and carriage returns",
+			input: "\uFEFFa 1\r\nb {\r\n c 2\r\n}\r\n",
+			want:  "a 1\nb {\n    c 2\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format([]byte(tt.input), "test.conf")
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			again, err := Format(got, "test.conf")
+			if err != nil {
+				t.Fatalf("Format() of formatted source error = %v", err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("Format() is not idempotent, got\n%s", again)
+			}
+
+			before, err := Read(strings.NewReader(tt.input), "test.conf", WithEnvMap(nil))
+			if err != nil {
+				t.Fatalf("Read() of input error = %v", err)
+			}
+			after, err := Read(strings.NewReader(string(got)), "test.conf", WithEnvMap(nil))
+			if err != nil {
+				t.Fatalf("Read() of formatted source error = %v", err)
+			}
+			if !equalNodes(before, after) {
+				t.Errorf("Format() changed the configuration from %v to %v", before, after)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "}\n", want: `test.conf:1: unexpected "}" outside of a block`},
+		{input: "a 1 }\n", want: `test.conf:1: unexpected "}" outside of a block`},
+		{input: "a {\n  b\n", want: `test.conf:1: block is not closed, "}" expected before the end of the file`},
+		{input: "a {\n} b\n", want: `test.conf:2: unexpected b after "}", a closing brace must end its line`},
+		{input: "{ a\n", want: `test.conf:1: a block needs a name before "{"`},
+		{input: "a \"b\n", want: "test.conf:1: unterminated quoted string"},
+		{input: "a {\n  b \"c \\\"d\n}\n", want: "test.conf:2: unterminated quoted string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Format([]byte(tt.input), "test.conf")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Format() error = %v, want %q", err, tt.want)
+			}
+			// Format reports the same errors as the parser.
+			_, err = Read(strings.NewReader(tt.input), "test.conf")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Read() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// importFS locates and opens the files referenced by import directives.
+type importFS interface {
+	// resolve returns the name of the file imported as name by the file at location
+	resolve(location, name string) string
+	// open opens the named file for reading
+	open(name string) (io.ReadCloser, error)
+	// glob returns the names of all files matching pattern
+	glob(pattern string) ([]string, error)
+	// within reports whether the named file lies within the directory root
+	within(root, name string) (bool, error)
+}
+
+// osFS resolves imports on the operating system's file system.
+// Relative names are resolved against the directory of the importing file.
+type osFS struct{}
+
+func (osFS) resolve(location, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(location), name)
+}
+
+func (osFS) open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (osFS) glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// within checks both the name and the file it refers to after resolving symbolic links.
+// A file that doesn't exist is within root if its name is.
+func (osFS) within(root, name string) (bool, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false, err
+	}
+	name, err = filepath.Abs(name)
+	if err != nil {
+		return false, err
+	}
+	if !isWithin(root, name) {
+		return false, nil
+	}
+
+	realName, err := filepath.EvalSymlinks(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false, err
+	}
+	return isWithin(realRoot, realName), nil
+}
+
+// isWithin reports whether the absolute, clean path name lies within the directory root.
+func isWithin(root, name string) bool {
+	rel, err := filepath.Rel(root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ioFS resolves imports within an fs.FS. Relative names are resolved against the
+// directory of the importing file, absolute names against the root of the file system.
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (f ioFS) resolve(location, name string) string {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(name[1:])
+	}
+	return path.Join(path.Dir(location), name)
+}
+
+func (f ioFS) open(name string) (io.ReadCloser, error) {
+	return f.fsys.Open(name)
+}
+
+func (f ioFS) glob(pattern string) ([]string, error) {
+	return fs.Glob(f.fsys, pattern)
+}
+
+// within only compares names, as an fs.FS doesn't expose symbolic links.
+func (f ioFS) within(root, name string) (bool, error) {
+	root = path.Clean(strings.TrimPrefix(root, "/"))
+	return root == "." || name == root || strings.HasPrefix(name, root+"/"), nil
+}
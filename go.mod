@@ -5,4 +5,5 @@ go 1.23.2
 require (
 	github.com/foxcpp/maddy v0.7.1
 	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
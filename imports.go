@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// expandImports returns list with import directives replaced by the nodes they
+// import and conditional blocks by the nodes of their selected branch, both in
+// list and in the blocks it contains. Conditions are evaluated first, so imports
+// in branches that aren't selected are never resolved. depth counts the enclosing
+// blocks and imports, to stop a snippet that imports itself.
+func (ctx *parseContext) expandImports(list []parser.Node, depth int) ([]parser.Node, error) {
+	// Keep nil, which marks a directive rather than an empty block.
+	if list == nil {
+		return nil, nil
+	}
+
+	res := make([]parser.Node, 0, len(list))
+	chain := noCondition
+	for _, node := range list {
+		if isConditional(node) {
+			selected, err := ctx.selectBranch(node, &chain)
+			if err != nil {
+				return nil, err
+			}
+			if selected {
+				branch, err := ctx.expandImports(node.Children, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, branch...)
+			}
+			continue
+		}
+		chain = noCondition
+
+		if node.Name == "import" {
+			imported, err := ctx.expandImport(node, depth)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, imported...)
+			continue
+		}
+
+		var err error
+		node.Children, err = ctx.expandImports(node.Children, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, node)
+	}
+	return res, nil
+}
+
+// expandImport returns the nodes imported by the import directive node, with
+// their own imports expanded.
+func (ctx *parseContext) expandImport(node parser.Node, depth int) ([]parser.Node, error) {
+	if len(node.Args) == 0 {
+		return nil, nodes.NodeErr(node, "import needs the name of a snippet or file")
+	}
+	if depth > maxNesting {
+		return nil, nodes.NodeErr(node, "import of %s: imports and blocks are nested more than %d levels deep", node.Args[0], maxNesting)
+	}
+	return ctx.resolveImport(node, node.Args[0], depth)
+}
+
+// resolveImport returns the nodes referenced by an import directive, with the
+// import arguments substituted and their imports expanded. Snippets take
+// precedence over files; file names are relative to the importing file and the
+// ".conf" extension may be omitted. Glob patterns import all matching files in
+// lexical order.
+func (ctx *parseContext) resolveImport(node parser.Node, name string, depth int) ([]parser.Node, error) {
+	if subtree, ok := ctx.snippets[name]; ok {
+		subtree, err := substituteImportArgs(node, subtree, node.Args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return ctx.expandImports(subtree, depth+1)
+	}
+
+	if ctx.noFileImports {
+		return nil, nodes.NodeErr(node, "unknown snippet %s, file imports are disabled", name)
+	}
+	if ctx.importRoots != nil && (filepath.IsAbs(name) || strings.HasPrefix(name, "/")) {
+		return nil, nodes.NodeErr(node, "absolute import path %s is not allowed", name)
+	}
+
+	if isGlob(name) {
+		return ctx.importGlob(node, name, depth)
+	}
+
+	file := ctx.fsys.resolve(ctx.location, name)
+	for _, candidate := range []string{file, file + ".conf"} {
+		src, err := ctx.openImport(node, candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		return ctx.importFile(node, src, candidate, depth)
+	}
+	return nil, nodes.NodeErr(node, "unknown import %s, there is no snippet or file of this name", name)
+}
+
+// importGlob imports all files matching pattern in lexical order.
+// A pattern matching no files is reported as a warning.
+func (ctx *parseContext) importGlob(node parser.Node, pattern string, expansionDepth int) ([]parser.Node, error) {
+	resolved := ctx.fsys.resolve(ctx.location, pattern)
+	ctx.addSourcePattern(resolved)
+	files, err := ctx.fsys.glob(resolved)
+	if err != nil {
+		return nil, nodes.NodeErr(node, "invalid import pattern %s: %v", pattern, err)
+	}
+	if len(files) == 0 {
+		return nil, ctx.warn(nodes.NodeErr(node, "no files match import pattern %s", pattern))
+	}
+	sort.Strings(files)
+
+	var res []parser.Node
+	for _, file := range files {
+		src, err := ctx.openImport(node, file)
+		if err != nil {
+			return nil, err
+		}
+		subtree, err := ctx.importFile(node, src, file, expansionDepth)
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, subtree...)
+	}
+	return res, nil
+}
+
+// openImport opens an imported file after checking that it lies within the import roots.
+// node is the import directive. Errors accessing the file are reported at node
+// and wrap the underlying error, e.g. a *fs.PathError.
+func (ctx *parseContext) openImport(node parser.Node, file string) (io.ReadCloser, error) {
+	ctx.addSourceFile(file)
+	ok, err := ctx.allowedFile(file)
+	if err != nil {
+		return nil, nodes.NodeErr(node, "import of %s: %w", file, err)
+	}
+	if !ok {
+		return nil, nodes.NodeErr(node, "import of %s is outside the allowed directories", file)
+	}
+	src, err := ctx.fsys.open(file)
+	if err != nil {
+		return nil, nodes.NodeErr(node, "import of %s: %w", file, err)
+	}
+	return src, nil
+}
+
+// allowedFile reports whether file may be read, i.e. whether it lies within one
+// of the import roots if they are restricted.
+func (l *loader) allowedFile(file string) (bool, error) {
+	if l.importRoots == nil {
+		return true, nil
+	}
+
+	for _, root := range l.importRoots {
+		ok, err := l.fsys.within(root, file)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// importFile parses an imported file and makes its snippets and macros
+// available to the importing file. node is the import directive.
+func (ctx *parseContext) importFile(node parser.Node, src io.Reader, file string, expansionDepth int) ([]parser.Node, error) {
+	for i, f := range ctx.importChain {
+		if f == file {
+			cycle := append(ctx.importChain[i:len(ctx.importChain):len(ctx.importChain)], file)
+			return nil, nodes.NodeErr(node, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if ctx.maxImportDepth > 0 && len(ctx.importChain) > ctx.maxImportDepth {
+		return nil, nodes.NodeErr(node, "import of %s exceeds the import depth limit of %d", file, ctx.maxImportDepth)
+	}
+	ctx.importCount++
+	if ctx.maxImportFiles > 0 && ctx.importCount > ctx.maxImportFiles {
+		return nil, nodes.NodeErr(node, "import of %s exceeds the limit of %d imported files", file, ctx.maxImportFiles)
+	}
+
+	if ctx.origins != nil {
+		ctx.origins.addImport(file, node)
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, nodes.NodeErr(node, "import of %s: %w", file, err)
+	}
+	ctx.importChain = append(ctx.importChain, file)
+	subtree, snippets, macros, err := ctx.readTree(data, file, expansionDepth+1, &node)
+	ctx.importChain = ctx.importChain[:len(ctx.importChain)-1]
+	if err != nil {
+		return subtree, err
+	}
+	for k, v := range snippets {
+		ctx.snippets[k] = v
+	}
+	// Only declared macros are merged, so that predefined values don't replace
+	// redefinitions in the importing file.
+	for k, v := range macros {
+		ctx.macros[k] = v
+		ctx.declared[k] = v
+	}
+
+	return subtree, nil
+}
+
+// importArgRe matches an {args[...]} placeholder, including an optional escaping backslash.
+var importArgRe = regexp.MustCompile(`\\?\{args\[([^\]{}]*)\]\}`)
+
+// substituteImportArgs replaces {args[i]} and {args[i:j]} placeholders in the
+// arguments of the imported nodes with the arguments of the import directive node.
+// A range must be a whole argument and is replaced by all arguments in the range.
+func substituteImportArgs(node parser.Node, list []parser.Node, args []string) ([]parser.Node, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	res := make([]parser.Node, 0, len(list))
+	for _, n := range list {
+		newArgs := make([]string, 0, len(n.Args))
+		for _, arg := range n.Args {
+			if m := importArgRe.FindStringSubmatch(arg); m != nil && m[0] == arg && arg[0] != '\\' {
+				lo, hi, err := parseArgsIndex(m[1], len(args))
+				if err != nil {
+					return nil, nodes.NodeErr(node, "import %s: %s: %v", node.Args[0], arg, err)
+				}
+				newArgs = append(newArgs, args[lo:hi]...)
+				continue
+			}
+
+			var err error
+			arg = importArgRe.ReplaceAllStringFunc(arg, func(p string) string {
+				if p[0] == '\\' || err != nil {
+					return p
+				}
+				spec := p[len("{args[") : len(p)-len("]}")]
+				if strings.Contains(spec, ":") {
+					err = nodes.NodeErr(node, "import %s: argument range %s must be a whole argument", node.Args[0], p)
+					return p
+				}
+				lo, _, indexErr := parseArgsIndex(spec, len(args))
+				if indexErr != nil {
+					err = nodes.NodeErr(node, "import %s: %s: %v", node.Args[0], p, indexErr)
+					return p
+				}
+				return args[lo]
+			})
+			if err != nil {
+				return nil, err
+			}
+			newArgs = append(newArgs, arg)
+		}
+		n.Args = newArgs
+
+		var err error
+		n.Children, err = substituteImportArgs(node, n.Children, args)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// parseArgsIndex parses the index i or range i:j of an {args[...]} placeholder
+// for n arguments and returns the bounds of the selected arguments.
+// Omitted range bounds default to the first and last argument.
+func parseArgsIndex(spec string, n int) (int, int, error) {
+	parse := func(s string, def int) (int, error) {
+		if s == "" {
+			return def, nil
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 {
+			return 0, fmt.Errorf("invalid argument index %q", s)
+		}
+		return i, nil
+	}
+
+	first, last, isRange := strings.Cut(spec, ":")
+	if !isRange {
+		if first == "" {
+			return 0, 0, fmt.Errorf("missing argument index")
+		}
+		i, err := parse(first, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		if i >= n {
+			return 0, 0, fmt.Errorf("argument index %d out of range, %d arguments given", i, n)
+		}
+		return i, i + 1, nil
+	}
+
+	lo, err := parse(first, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := parse(last, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lo > hi || hi > n {
+		return 0, 0, fmt.Errorf("argument range %s out of range, %d arguments given", spec, n)
+	}
+	return lo, hi, nil
+}
+
+// isGlob reports whether an import name is a glob pattern.
+func isGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
@@ -0,0 +1,546 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// nodeNames returns the names of the top-level nodes of ast, separated by spaces.
+func nodeNames(ast AST) string {
+	var names []string
+	for _, node := range ast {
+		names = append(names, node.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestReadGlobImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf":            {Data: []byte("first\nimport conf.d/*.conf\nimport snip\nlast")},
+		"conf.d/20-b.conf":    {Data: []byte("b")},
+		"conf.d/10-a.conf":    {Data: []byte("(snip) {\n    from_snippet\n}\na")},
+		"conf.d/30-c.conf":    {Data: []byte("c")},
+		"conf.d/README":       {Data: []byte("not a config file")},
+		"empty.conf":          {Data: []byte("first\nimport empty.d/*.conf\nlast")},
+		"bad_pattern.conf":    {Data: []byte("import conf.d/[.conf")},
+		"nested.conf":         {Data: []byte("server {\n    import conf.d/1*\n}")},
+		"empty.d/ignored.txt": {Data: []byte("ignored")},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "matches in lexical order",
+			file: "app.conf",
+			want: "first a b c from_snippet last",
+		},
+		{
+			name: "glob inside a block",
+			file: "nested.conf",
+			want: "server",
+		},
+		{
+			name:    "no matches without warning handler",
+			file:    "empty.conf",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			file:    "bad_pattern.conf",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := ReadFS(fsys, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && nodeNames(ast) != tt.want {
+				t.Errorf("ReadFS() returned nodes %q, want %q", nodeNames(ast), tt.want)
+			}
+		})
+	}
+}
+
+func TestReadGlobImportsWarning(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf": {Data: []byte("first\nimport conf.d/*.conf\nlast")},
+	}
+
+	var warnings []error
+	ast, err := ReadFS(fsys, "app.conf", WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+	if nodeNames(ast) != "first last" {
+		t.Errorf("ReadFS() returned nodes %q, want %q", nodeNames(ast), "first last")
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(warnings))
+	}
+	if !strings.Contains(warnings[0].Error(), "app.conf:2") || !strings.Contains(warnings[0].Error(), "conf.d/*.conf") {
+		t.Errorf("Warning should name the position and pattern, got: %v", warnings[0])
+	}
+}
+
+func TestReadFileGlobImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.conf":         "import conf.d/*.conf",
+		"conf.d/b.conf":    "b",
+		"conf.d/a.conf":    "a",
+		"conf.d/c.conf.bk": "backup",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ast, err := ReadFile(filepath.Join(dir, "app.conf"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if nodeNames(ast) != "a b" {
+		t.Errorf("ReadFile() returned nodes %q, want %q", nodeNames(ast), "a b")
+	}
+	if ast[0].File != filepath.Join(dir, "conf.d/a.conf") {
+		t.Errorf("Expected node from %s, got %s", filepath.Join(dir, "conf.d/a.conf"), ast[0].File)
+	}
+}
+
+func TestReadImportCycles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"self.conf":     {Data: []byte("a\nimport self.conf")},
+		"a.conf":        {Data: []byte("import b.conf")},
+		"b.conf":        {Data: []byte("import c")},
+		"c.conf":        {Data: []byte("server {\n    import a.conf\n}")},
+		"g/main.conf":   {Data: []byte("import *.conf")},
+		"g/leaf.conf":   {Data: []byte("leaf")},
+		"twice.conf":    {Data: []byte("import leaf.conf\nimport leaf.conf")},
+		"leaf.conf":     {Data: []byte("leaf")},
+		"d/nested.conf": {Data: []byte("import ../a.conf")},
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		wantCycle string
+	}{
+		{
+			name:      "file importing itself",
+			file:      "self.conf",
+			wantCycle: "import cycle: self.conf -> self.conf",
+		},
+		{
+			name:      "indirect cycle",
+			file:      "a.conf",
+			wantCycle: "c.conf:2: import cycle: a.conf -> b.conf -> c.conf -> a.conf",
+		},
+		{
+			name:      "cycle not including the main file",
+			file:      "d/nested.conf",
+			wantCycle: "import cycle: a.conf -> b.conf -> c.conf -> a.conf",
+		},
+		{
+			name:      "glob matching the importing file",
+			file:      "g/main.conf",
+			wantCycle: "import cycle: g/main.conf -> g/main.conf",
+		},
+		{
+			name: "repeated import is not a cycle",
+			file: "twice.conf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFS(fsys, tt.file)
+			if tt.wantCycle == "" {
+				if err != nil {
+					t.Errorf("ReadFS() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ReadFS() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantCycle) {
+				t.Errorf("ReadFS() error = %v, want it to contain %q", err, tt.wantCycle)
+			}
+		})
+	}
+}
+
+func TestReadImportLimits(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf":    {Data: []byte("import one.conf\nimport other.conf")},
+		"one.conf":    {Data: []byte("import two.conf")},
+		"two.conf":    {Data: []byte("import three.conf")},
+		"three.conf":  {Data: []byte("three")},
+		"other.conf":  {Data: []byte("other")},
+		"repeat.conf": {Data: []byte("import other.conf\nimport other.conf\nimport other.conf")},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		options []ReadOption
+		wantErr string
+	}{
+		{
+			name: "no limits",
+			file: "app.conf",
+		},
+		{
+			name:    "depth within limit",
+			file:    "app.conf",
+			options: []ReadOption{WithMaxImportDepth(3)},
+		},
+		{
+			name:    "depth exceeded",
+			file:    "app.conf",
+			options: []ReadOption{WithMaxImportDepth(2)},
+			wantErr: "two.conf:1: import of three.conf exceeds the import depth limit of 2",
+		},
+		{
+			name:    "file count within limit",
+			file:    "app.conf",
+			options: []ReadOption{WithMaxImportFiles(4)},
+		},
+		{
+			name:    "file count exceeded",
+			file:    "app.conf",
+			options: []ReadOption{WithMaxImportFiles(3)},
+			wantErr: "app.conf:2: import of other.conf exceeds the limit of 3 imported files",
+		},
+		{
+			name:    "repeated imports are counted",
+			file:    "repeat.conf",
+			options: []ReadOption{WithMaxImportFiles(2)},
+			wantErr: "repeat.conf:3:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFS(fsys, tt.file, tt.options...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ReadFS() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadFS() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFileImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.conf")
+	b := filepath.Join(dir, "b.conf")
+	if err := os.WriteFile(a, []byte("import b.conf"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("import "+a), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadFile(a)
+	want := "import cycle: " + a + " -> " + b + " -> " + a
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ReadFile() error = %v, want it to contain %q", err, want)
+	}
+}
+
+func TestReadImportRoots(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "tenant")
+	files := map[string]string{
+		"secret.conf":          "secret",
+		"tenant/inside.conf":   "inside",
+		"tenant/sub/deep.conf": "deep",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.conf"), filepath.Join(root, "link.conf")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "relative import inside the root",
+			content: "import inside.conf\nimport sub/deep",
+		},
+		{
+			name:    "parent directory escape",
+			content: "import ../secret.conf",
+			wantErr: "is outside the allowed directories",
+		},
+		{
+			name:    "escape hidden in a longer path",
+			content: "import sub/../../secret.conf",
+			wantErr: "is outside the allowed directories",
+		},
+		{
+			name:    "absolute path",
+			content: "import " + filepath.Join(root, "inside.conf"),
+			wantErr: "absolute import path",
+		},
+		{
+			name:    "symbolic link out of the root",
+			content: "import link.conf",
+			wantErr: "is outside the allowed directories",
+		},
+		{
+			name:    "glob matching a symbolic link out of the root",
+			content: "import *.conf",
+			wantErr: "link.conf is outside the allowed directories",
+		},
+		{
+			name:    "missing file inside the root",
+			content: "import missing.conf",
+			wantErr: "unknown import missing.conf,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.content), filepath.Join(root, "app.conf"), WithImportRoots(root))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Read() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFSImportRoots(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tenant/app.conf":    {Data: []byte("import inside.conf")},
+		"tenant/inside.conf": {Data: []byte("inside")},
+		"tenant/escape.conf": {Data: []byte("import ../shared.conf")},
+		"tenants.conf":       {Data: []byte("import tenant/inside.conf")},
+		"shared.conf":        {Data: []byte("shared")},
+	}
+
+	if _, err := ReadFS(fsys, "tenant/app.conf", WithImportRoots("tenant")); err != nil {
+		t.Errorf("ReadFS() error = %v", err)
+	}
+	if _, err := ReadFS(fsys, "tenant/escape.conf", WithImportRoots("tenant")); err == nil {
+		t.Error("ReadFS() expected error for an import outside the root, got nil")
+	}
+	if _, err := ReadFS(fsys, "tenants.conf", WithImportRoots("tenant", "other")); err != nil {
+		t.Errorf("ReadFS() error = %v", err)
+	}
+}
+
+func TestReadWithoutFileImports(t *testing.T) {
+	content := "(common) {\n    timeout 30\n}\nimport common\nimport testdata/simple.conf"
+
+	_, err := Read(strings.NewReader(content), "test.conf", WithoutFileImports())
+	if err == nil || !strings.Contains(err.Error(), "test.conf:5: unknown snippet testdata/simple.conf, file imports are disabled") {
+		t.Errorf("Read() error = %v, want file imports to be rejected", err)
+	}
+
+	ast, err := Read(strings.NewReader("(common) {\n    timeout 30\n}\nimport common"), "test.conf", WithoutFileImports())
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if nodeNames(ast) != "timeout" {
+		t.Errorf("Read() returned nodes %q, want %q", nodeNames(ast), "timeout")
+	}
+}
+
+// deniedFS is a file system whose file "denied.conf" can't be opened.
+type deniedFS struct {
+	fstest.MapFS
+}
+
+func (fsys deniedFS) Open(name string) (fs.File, error) {
+	if name == "denied.conf" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func TestReadUnreadableImport(t *testing.T) {
+	fsys := deniedFS{fstest.MapFS{
+		"app.conf":    {Data: []byte("first\nimport denied")},
+		"glob.conf":   {Data: []byte("import denie*.conf")},
+		"denied.conf": {Data: []byte("a")},
+	}}
+
+	for _, file := range []string{"app.conf", "glob.conf"} {
+		_, err := ReadFS(fsys, file)
+		if err == nil {
+			t.Fatalf("ReadFS(%s) error = nil, want an error", file)
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("ReadFS(%s) error = %v, want it to wrap the *fs.PathError", file, err)
+		}
+		if !strings.HasPrefix(err.Error(), file+":") {
+			t.Errorf("ReadFS(%s) error = %v, want the position of the import", file, err)
+		}
+	}
+}
+
+func TestReadImportArgs(t *testing.T) {
+	snippet := `(tls_site) {
+    site {args[0]} {
+        tls_cert {args[1]}/{args[0]}.pem
+        aliases {args[2:]}
+    }
+}
+`
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "index and range",
+			content: snippet + "import tls_site example.org /etc/ssl www.example.org mail.example.org",
+			want:    "site example.org {\n    tls_cert /etc/ssl/example.org.pem\n    aliases www.example.org mail.example.org\n}\n",
+		},
+		{
+			name:    "empty range",
+			content: snippet + "import tls_site example.org /etc/ssl",
+			want:    "site example.org {\n    tls_cert /etc/ssl/example.org.pem\n    aliases\n}\n",
+		},
+		{
+			name:    "repeated imports",
+			content: "(s) {\n    listen {args[0]}\n}\nimport s 25\nimport s 587",
+			want:    "listen 25\nlisten 587\n",
+		},
+		{
+			name:    "whole range",
+			content: "(s) {\n    listen {args[:]}\n    first {args[:1]}\n}\nimport s 25 587",
+			want:    "listen 25 587\nfirst 25\n",
+		},
+		{
+			name:    "nested imports",
+			content: "(inner) {\n    inner {args[0]}\n}\n(outer) {\n    import inner {args[1]}-{args[0]}\n}\nimport outer a b",
+			want:    "inner b-a\n",
+		},
+		{
+			name:    "inside a block",
+			content: "(s) {\n    listen {args[0]}\n}\nserver {\n    import s 80\n}",
+			want:    "server {\n    listen 80\n}\n",
+		},
+		{
+			name:    "escaped",
+			content: "(s) {\n    literal \\{args[0]} x{args[0]}\n}\nimport s 1",
+			want:    "literal \\{args[0]} x1\n",
+		},
+		{
+			name:    "out of range",
+			content: snippet + "import tls_site example.org",
+			wantErr: "test.conf:7: import tls_site: {args[1]}: argument index 1 out of range, 1 arguments given",
+		},
+		{
+			name:    "range out of range",
+			content: "(s) {\n    a {args[1:3]}\n}\nimport s x y",
+			wantErr: "test.conf:4: import s: {args[1:3]}: argument range 1:3 out of range, 2 arguments given",
+		},
+		{
+			name:    "inline range",
+			content: "(s) {\n    a x{args[:]}\n}\nimport s x",
+			wantErr: "test.conf:4: import s: argument range {args[:]} must be a whole argument",
+		},
+		{
+			name:    "invalid index",
+			content: "(s) {\n    a {args[-1]}\n}\nimport s x",
+			wantErr: `test.conf:4: import s: {args[-1]}: invalid argument index "-1"`,
+		},
+		{
+			name:    "outside imports",
+			content: "a {args[0]}",
+			wantErr: "test.conf:1: {args[0]} can only be used in imported snippets and files",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			got, err := Marshal(ast)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Read() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileImportArgs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf":     {Data: []byte("import site.conf example.org 443\nimport sites/*.conf shared")},
+		"site.conf":    {Data: []byte("site {args[0]} {\n    listen {args[1]}\n}")},
+		"sites/a.conf": {Data: []byte("a {args[0]}")},
+		"sites/b.conf": {Data: []byte("b {args[0]}")},
+	}
+
+	ast, err := ReadFS(fsys, "app.conf")
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+	got, err := Marshal(ast)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "site example.org {\n    listen 443\n}\na shared\nb shared\n"
+	if string(got) != want {
+		t.Errorf("ReadFS() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// sourceToken is a token of configuration source: a word, or a quoted string
+// that may contain spaces and span several lines.
+type sourceToken struct {
+	// text is the token as written, including quotes
+	text string
+	// value is the token as read, without quotes and with escaped quotes unescaped
+	value string
+	// quoted reports whether the token is quoted
+	quoted bool
+	// line is the line the token starts on
+	line int
+	// endLine is the line the token ends on, which differs from line for quoted
+	// tokens spanning several lines
+	endLine int
+}
+
+// sourceLine holds the tokens of a source line, where the line of a token
+// spanning several lines extends to its last line. A node can't end before
+// the end of its line.
+type sourceLine struct {
+	// line is the number of the first line
+	line int
+	// blank reports whether a blank line precedes the line
+	blank bool
+	// tokens are the tokens of the line other than comments
+	tokens []sourceToken
+	// comment is the comment ending the line, including #, if any
+	comment string
+}
+
+// lexLines splits src into lines of tokens, keeping the source form of each
+// token and the comments for Format.
+//
+// Tokens are separated by whitespace. A token starting with a double quote
+// extends to the next unescaped double quote; \" stands for a quote and other
+// backslashes are kept. A # outside of quotes starts a comment that extends to
+// the end of the line. Braces are tokens of their own only when separated by
+// whitespace.
+//
+// A quoted string that isn't closed before the end of src is an error, reported
+// at the line of its opening quote. The lines before the line of the string are
+// returned nonetheless, so that errors before it can be reported first.
+// location is used in errors.
+func lexLines(src []byte, location string) ([]sourceLine, error) {
+	s := strings.TrimPrefix(string(src), "\uFEFF")
+
+	var lines []sourceLine
+	var cur *sourceLine
+	lineNo, lastLine := 1, 0
+	// add adds a token or comment on lineNo to the current line, starting a new one if needed
+	add := func() *sourceLine {
+		if cur == nil || cur.line+lineBreaks(cur) < lineNo {
+			lines = append(lines, sourceLine{line: lineNo, blank: lastLine != 0 && lineNo > lastLine+1})
+			cur = &lines[len(lines)-1]
+		}
+		return cur
+	}
+
+	for i := 0; i < len(s); {
+		switch ch := s[i]; {
+		case ch == '\n':
+			lineNo++
+			i++
+		case ch == '#':
+			end := strings.IndexByte(s[i:], '\n')
+			if end == -1 {
+				end = len(s) - i
+			}
+			add().comment = strings.TrimRightFunc(s[i:i+end], unicode.IsSpace)
+			lastLine = lineNo
+			i += end
+		case isSpace(s[i:]):
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		case ch == '"':
+			l := add()
+			token := sourceToken{quoted: true, line: lineNo}
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					if s[j] != '"' {
+						value.WriteByte('\\')
+					}
+				}
+				if s[j] == '\n' {
+					lineNo++
+				}
+				value.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return lines[:len(lines)-1], nodes.NodeErr(parser.Node{File: location, Line: token.line}, "unterminated quoted string")
+			}
+			j++
+			token.text, token.value, token.endLine = s[i:j], value.String(), lineNo
+			l.tokens = append(l.tokens, token)
+			lastLine = lineNo
+			i = j
+		default:
+			j := i
+			for j < len(s) && s[j] != '#' && !isSpace(s[j:]) {
+				j++
+			}
+			l := add()
+			l.tokens = append(l.tokens, sourceToken{text: s[i:j], value: s[i:j], line: lineNo, endLine: lineNo})
+			lastLine = lineNo
+			i = j
+		}
+	}
+	return lines, nil
+}
+
+// lineBreaks returns the number of line breaks within the tokens of line.
+func lineBreaks(line *sourceLine) int {
+	if len(line.tokens) == 0 {
+		return 0
+	}
+	last := line.tokens[len(line.tokens)-1]
+	return last.endLine - line.line
+}
+
+// isSpace reports whether s starts with a whitespace character.
+func isSpace(s string) bool {
+	for _, r := range s {
+		return unicode.IsSpace(r)
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"regexp"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// macroRe matches a macro reference such as $(name), including an optional
+// escaping backslash.
+var macroRe = regexp.MustCompile(`\\?\$\(([^$()]+)\)`)
+
+// expandMacros replaces the macro references in the arguments of node. The
+// children of blocks are expanded when they are parsed.
+//
+// An argument that is a single reference is replaced by all values of the
+// macro, so an undefined macro removes the argument. A reference within a
+// longer argument is replaced by the only value of the macro, or by nothing.
+// Escaped references are kept for expandPlaceholders to unescape.
+func (ctx *parseContext) expandMacros(node *parser.Node) error {
+	args := make([]string, 0, len(node.Args))
+	for _, arg := range node.Args {
+		if m := macroRe.FindStringSubmatch(arg); m != nil && m[0] == arg && arg[0] != '\\' {
+			args = append(args, ctx.macros[m[1]]...)
+			continue
+		}
+
+		var err error
+		arg = macroRe.ReplaceAllStringFunc(arg, func(ref string) string {
+			if ref[0] == '\\' {
+				return ref
+			}
+			name := ref[2 : len(ref)-1]
+			switch values := ctx.macros[name]; len(values) {
+			case 0:
+				return ""
+			case 1:
+				return values[0]
+			default:
+				if err == nil {
+					err = nodes.NodeErr(*node, "macro $(%s) has %d values and can't be part of a longer argument", name, len(values))
+				}
+				return ref
+			}
+		})
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+	}
+	node.Args = args
+	return nil
+}
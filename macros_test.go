@@ -0,0 +1,142 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadWithMacros(t *testing.T) {
+	predefined := WithMacros(map[string][]string{
+		"hostname": {"mx.example.org"},
+		"ports":    {"25", "587"},
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		options []ReadOption
+		want    []string
+		wantErr string
+	}{
+		{name: "whole argument", content: "listen $(ports)", options: []ReadOption{predefined}, want: []string{"25", "587"}},
+		{name: "inline", content: "url https://$(hostname)/", options: []ReadOption{predefined}, want: []string{"https://mx.example.org/"}},
+		{name: "redefined", content: "$(hostname) = other.org\nhost $(hostname)", options: []ReadOption{predefined}, want: []string{"other.org"}},
+		{name: "used in declaration", content: "$(url) = https://$(hostname)\nurl $(url)", options: []ReadOption{predefined}, want: []string{"https://mx.example.org"}},
+		{
+			name:    "placeholder in value",
+			content: "host $(host)",
+			options: []ReadOption{WithMacros(map[string][]string{"host": {"{env:HOST}"}}), WithEnvMap(map[string]string{"HOST": "from-env"})},
+			want:    []string{"from-env"},
+		},
+		{
+			name:    "repeated options",
+			content: "host $(a) $(b)",
+			options: []ReadOption{WithMacros(map[string][]string{"a": {"1"}}), WithMacros(map[string][]string{"b": {"2"}})},
+			want:    []string{"1", "2"},
+		},
+		{
+			name:    "redefinition forbidden",
+			content: "host a\n$(hostname) = other.org",
+			options: []ReadOption{predefined, WithoutMacroRedefinition()},
+			wantErr: "predefined macro hostname can't be redefined",
+		},
+		{
+			name:    "other macros with redefinition forbidden",
+			content: "$(other) = x\nhost $(other) $(hostname)",
+			options: []ReadOption{predefined, WithoutMacroRedefinition()},
+			want:    []string{"x", "mx.example.org"},
+		},
+		{
+			name:    "invalid name",
+			content: "host a",
+			options: []ReadOption{WithMacros(map[string][]string{"bad name": {"x"}})},
+			wantErr: `invalid macro name "bad name"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf", tt.options...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if strings.Join(ast[0].Args, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Read() args = %q, want %q", ast[0].Args, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWithMacrosInImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":     {Data: []byte("import site.conf\n")},
+		"site.conf":     {Data: []byte("host $(hostname)\n")},
+		"locked.conf":   {Data: []byte("import redefine.conf\n")},
+		"redefine.conf": {Data: []byte("$(hostname) = other.org\n")},
+	}
+	predefined := WithMacros(map[string][]string{"hostname": {"mx.example.org"}})
+
+	ast, err := ReadFS(fsys, "main.conf", predefined)
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+	if len(ast) != 1 || ast[0].Args[0] != "mx.example.org" {
+		t.Errorf("ReadFS() = %+v, want the predefined macro expanded in the imported file", ast)
+	}
+
+	_, err = ReadFS(fsys, "locked.conf", predefined, WithoutMacroRedefinition())
+	if err == nil || !strings.Contains(err.Error(), "redefine.conf:1") || !strings.Contains(err.Error(), "predefined macro hostname") {
+		t.Errorf("ReadFS() error = %v, want redefinition error in the imported file", err)
+	}
+}
+
+func TestReadMacroTable(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf": {Data: []byte("$(a) = 1 2\n$(b) = $(a) 3 {env:X}\nimport more.conf\n")},
+		"more.conf": {Data: []byte("$(c) = c\n")},
+	}
+
+	var table map[string][]string
+	_, err := ReadFS(fsys, "main.conf", WithMacros(map[string][]string{"seed": {"s"}}), WithMacroTable(&table))
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+
+	want := map[string][]string{
+		"seed": {"s"},
+		"a":    {"1", "2"},
+		"b":    {"1", "2", "3", "{env:X}"},
+		"c":    {"c"},
+	}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("macro table = %v, want %v", table, want)
+	}
+}
+
+func TestReadMacroTableRedefinedBeforeImport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":  {Data: []byte("$(port) = 9090\nimport other.conf\nlisten $(port)\n")},
+		"other.conf": {Data: []byte("$(host) = example.org\n")},
+	}
+
+	var table map[string][]string
+	ast, err := ReadFS(fsys, "main.conf", WithMacros(map[string][]string{"port": {"8080"}}), WithMacroTable(&table))
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+	if ast[0].Args[0] != "9090" {
+		t.Errorf("ReadFS() = %+v, want the redefined macro expanded", ast)
+	}
+	want := map[string][]string{"port": {"9090"}, "host": {"example.org"}}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("macro table = %v, want %v", table, want)
+	}
+}
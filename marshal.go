@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// indentUnit is the indentation used for each nesting level of marshaled blocks.
+const indentUnit = "    "
+
+// Marshal serializes a configuration tree into its textual form.
+// Arguments are quoted and escaped where needed, so that reading the result back
+// yields the same tree. Comments and node positions are not preserved.
+// To dump a configuration with secrets, redact it first, see Secrets.Redact.
+func Marshal(ast AST) ([]byte, error) {
+	return MarshalAnnotated(ast, nil)
+}
+
+// MarshalAnnotated serializes a configuration tree like Marshal and appends the
+// text returned by annotate for each node as a comment to its line, e.g. the
+// origin of the node, see Origins.Of. Nodes for which annotate returns "" are
+// left without comment. A nil annotate adds no comments.
+func MarshalAnnotated(ast AST, annotate func(node parser.Node) string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalNodes(&buf, ast, 0, annotate); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalNodes writes nodes at the given nesting depth.
+func marshalNodes(buf *bytes.Buffer, list []parser.Node, depth int, annotate func(parser.Node) string) error {
+	for _, node := range list {
+		if err := marshalNode(buf, node, depth, annotate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalNode writes a single node, followed by its block if it has one.
+func marshalNode(buf *bytes.Buffer, node parser.Node, depth int, annotate func(parser.Node) string) error {
+	indent := strings.Repeat(indentUnit, depth)
+	buf.WriteString(indent)
+
+	switch {
+	case node.Macro:
+		if len(node.Args) == 0 {
+			return nodes.NodeErr(node, "macro '%s' has no value", node.Name)
+		}
+		fmt.Fprintf(buf, "$(%s) =", node.Name)
+	case node.Snippet:
+		if len(node.Args) != 0 {
+			return nodes.NodeErr(node, "snippet '%s' can't have arguments", node.Name)
+		}
+		fmt.Fprintf(buf, "(%s)", node.Name)
+	default:
+		if err := checkNodeName(node.Name); err != nil {
+			return nodes.NodeErr(node, "%v", err)
+		}
+		buf.WriteString(node.Name)
+	}
+
+	for i, arg := range node.Args {
+		quoted, err := quoteArg(arg)
+		if err != nil {
+			return nodes.NodeErr(node, "%s: %v", node.Name, err)
+		}
+		// A lone backslash at the end of a line continues the node on the next line.
+		if quoted == `\` && i == len(node.Args)-1 {
+			return nodes.NodeErr(node, "%s: trailing argument %q can't be represented", node.Name, arg)
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(quoted)
+	}
+
+	if node.Children != nil {
+		buf.WriteString(" {")
+	}
+	if annotate != nil {
+		if comment := annotate(node); comment != "" {
+			buf.WriteString(" # ")
+			buf.WriteString(strings.ReplaceAll(comment, "\n", " "))
+		}
+	}
+	buf.WriteByte('\n')
+	if node.Children == nil {
+		return nil
+	}
+
+	if err := marshalNodes(buf, node.Children, depth+1, annotate); err != nil {
+		return err
+	}
+	buf.WriteString(indent)
+	buf.WriteString("}\n")
+	return nil
+}
+
+// quoteArg returns the textual form of a single argument. Placeholders and macro
+// references are escaped so they are read back literally, and the argument is
+// quoted if it is empty or contains whitespace, comments or a leading quote.
+// It returns an error for the few strings the syntax can't represent.
+func quoteArg(arg string) (string, error) {
+	switch arg {
+	case "{":
+		return `\{`, nil
+	case "}":
+		return `\}`, nil
+	case `\{`, `\}`:
+		return "", fmt.Errorf("argument %q can't be represented", arg)
+	}
+
+	arg = placeholderRe.ReplaceAllString(arg, `\$0`)
+
+	needsQuotes := arg == "" || strings.HasPrefix(arg, `"`) || strings.ContainsAny(arg, "#") ||
+		strings.IndexFunc(arg, unicode.IsSpace) >= 0
+	if !needsQuotes {
+		return arg, nil
+	}
+
+	// Inside quotes a backslash only escapes a quote, so these sequences are ambiguous.
+	if strings.Contains(arg, `\"`) || strings.HasSuffix(arg, `\`) {
+		return "", fmt.Errorf("argument %q can't be represented", arg)
+	}
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`, nil
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+)
+
+// stripPositions returns a copy of nodes without file and line information,
+// so that trees read from different sources can be compared.
+func stripPositions(list []parser.Node) []parser.Node {
+	if list == nil {
+		return nil
+	}
+	out := make([]parser.Node, 0, len(list))
+	for _, node := range list {
+		node.File = ""
+		node.Line = 0
+		node.Children = stripPositions(node.Children)
+		out = append(out, node)
+	}
+	return out
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		ast  AST
+		want string
+	}{
+		{
+			name: "simple directive",
+			ast:  AST{{Name: "log_level", Args: []string{"info"}}},
+			want: "log_level info\n",
+		},
+		{
+			name: "nested blocks",
+			ast: AST{
+				{
+					Name: "server",
+					Args: []string{"web"},
+					Children: []parser.Node{
+						{Name: "listen", Args: []string{"80"}},
+						{Name: "tls", Children: []parser.Node{{Name: "cert", Args: []string{"a.pem"}}}},
+					},
+				},
+			},
+			want: "server web {\n    listen 80\n    tls {\n        cert a.pem\n    }\n}\n",
+		},
+		{
+			name: "empty block",
+			ast:  AST{{Name: "block", Children: []parser.Node{}}},
+			want: "block {\n}\n",
+		},
+		{
+			name: "quoted arguments",
+			ast:  AST{{Name: "d", Args: []string{"two words", "", `say "hi"`, "a#b", `"lead`}}},
+			want: `d "two words" "" "say \"hi\"" "a#b" "\"lead"` + "\n",
+		},
+		{
+			name: "escaped placeholders and macros",
+			ast:  AST{{Name: "d", Args: []string{"{env:HOME}", "$(name)", "{", "}", "a{2,3}", "{hostname} x"}}},
+			want: `d \{env:HOME} \$(name) \{ \} a{2,3} "\{hostname} x"` + "\n",
+		},
+		{
+			name: "snippet and macro declarations",
+			ast: AST{
+				{Name: "host", Args: []string{"example.org"}, Macro: true},
+				{Name: "common", Snippet: true, Children: []parser.Node{{Name: "listen", Args: []string{"80"}}}},
+			},
+			want: "$(host) = example.org\n(common) {\n    listen 80\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.ast)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ast  AST
+	}{
+		{name: "invalid name", ast: AST{{Name: "bad name"}}},
+		{name: "empty name", ast: AST{{Name: ""}}},
+		{name: "escaped quote in quoted argument", ast: AST{{Name: "d", Args: []string{`a \" b`}}}},
+		{name: "trailing backslash in quoted argument", ast: AST{{Name: "d", Args: []string{`a b\`}}}},
+		{name: "trailing line continuation", ast: AST{{Name: "d", Args: []string{`\`}}}},
+		{name: "escaped brace", ast: AST{{Name: "d", Args: []string{`\{`}}}},
+		{name: "macro without value", ast: AST{{Name: "m", Macro: true}}},
+		{name: "nested invalid name", ast: AST{{Name: "b", Children: []parser.Node{{Name: "1st"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Marshal(tt.ast); err == nil {
+				t.Error("Marshal() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	t.Setenv("SERVER_NAME", "test-server")
+	t.Setenv("LOG_DIR", "/tmp/logs")
+
+	files := []string{
+		"testdata/simple.conf",
+		"testdata/with_snippets.conf",
+		"testdata/with_macros.conf",
+		"testdata/with_env_vars.conf",
+		"testdata/with_imports.conf",
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			ast, err := ReadFile(file)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			assertRoundTrip(t, ast)
+		})
+	}
+
+	t.Run("special arguments", func(t *testing.T) {
+		assertRoundTrip(t, AST{
+			{
+				Name: "server",
+				Args: []string{"{env:SERVER_NAME}", "$(host)", "with space", "{", "}", "", "#", `\{env:X}`},
+				Children: []parser.Node{
+					{Name: "path", Args: []string{`C:\dir\file`, "a\nb", `"quoted"`, "$(a) and {b}"}},
+					{Name: "empty", Children: []parser.Node{}},
+				},
+			},
+		})
+	})
+}
+
+func assertRoundTrip(t *testing.T, ast AST) {
+	t.Helper()
+
+	data, err := Marshal(ast)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got, err := Read(strings.NewReader(string(data)), "marshaled.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v\n%s", err, data)
+	}
+
+	if !reflect.DeepEqual(normalizeArgs(stripPositions(got)), normalizeArgs(stripPositions(ast))) {
+		t.Errorf("round trip mismatch\nmarshaled:\n%s\ngot:  %+v\nwant: %+v", data, got, ast)
+	}
+}
+
+// normalizeArgs replaces nil argument lists with empty ones, as the parser never returns nil.
+func normalizeArgs(list []parser.Node) []parser.Node {
+	for i := range list {
+		if list[i].Args == nil {
+			list[i].Args = []string{}
+		}
+		normalizeArgs(list[i].Children)
+	}
+	return list
+}
+
+func TestMarshalAnnotated(t *testing.T) {
+	ast := AST{
+		{Name: "log_level", Args: []string{"info"}, File: "app.conf", Line: 1},
+		{Name: "server", Args: []string{"web"}, File: "app.conf", Line: 2, Children: []parser.Node{
+			{Name: "listen", Args: []string{":80"}, File: "site.conf", Line: 3},
+			{Name: "tls", File: "", Children: []parser.Node{}},
+		}},
+	}
+
+	data, err := MarshalAnnotated(ast, func(node parser.Node) string {
+		if node.File == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s:%d", node.File, node.Line)
+	})
+	if err != nil {
+		t.Fatalf("MarshalAnnotated() error = %v", err)
+	}
+
+	want := "log_level info # app.conf:1\nserver web { # app.conf:2\n    listen :80 # site.conf:3\n    tls {\n    }\n}\n"
+	if string(data) != want {
+		t.Errorf("MarshalAnnotated() =\n%s\nwant\n%s", data, want)
+	}
+
+	got, err := Read(strings.NewReader(string(data)), "annotated.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !equalNodes(got, ast) {
+		t.Errorf("Read() of annotated output = %+v, want %+v", got, ast)
+	}
+}
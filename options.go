@@ -0,0 +1,189 @@
+package config
+
+// ReadOption configures how configuration files are read.
+type ReadOption func(*loader)
+
+// WithWarningHandler passes conditions that may be intentional, such as an import
+// pattern matching no files, to handler and continues reading. Without a handler
+// such conditions fail the read.
+func WithWarningHandler(handler func(error)) ReadOption {
+	return func(l *loader) {
+		l.warningHandler = handler
+	}
+}
+
+// WithMaxImportDepth limits how deeply imported files may import further files.
+// Files imported by the main file are at depth 1. A depth of 0 means no limit.
+func WithMaxImportDepth(depth int) ReadOption {
+	return func(l *loader) {
+		l.maxImportDepth = depth
+	}
+}
+
+// WithMaxImportFiles limits the total number of files imported while reading,
+// counting each import of the same file. A limit of 0 means no limit.
+func WithMaxImportFiles(n int) ReadOption {
+	return func(l *loader) {
+		l.maxImportFiles = n
+	}
+}
+
+// WithImportRoots restricts file imports to files within the given directories.
+// Absolute import paths are rejected, and so are relative paths and symbolic links
+// leading out of the directories. Relative roots are resolved against the working
+// directory, or the root of the file system passed to ReadFS. Snippet imports are
+// not affected. {file:...} placeholders are restricted to the same directories,
+// but may use absolute paths.
+func WithImportRoots(roots ...string) ReadOption {
+	return func(l *loader) {
+		l.importRoots = append([]string{}, roots...)
+	}
+}
+
+// WithoutFileImports rejects all file imports, only snippets can be imported.
+// {file:...} placeholders are rejected as well.
+func WithoutFileImports() ReadOption {
+	return func(l *loader) {
+		l.noFileImports = true
+	}
+}
+
+// WithEnv resolves {env:NAME} placeholders using lookup instead of the process
+// environment. lookup returns the value of the variable and whether it is defined.
+func WithEnv(lookup func(name string) (string, bool)) ReadOption {
+	return func(l *loader) {
+		l.lookupEnv = lookup
+	}
+}
+
+// WithEnvMap resolves {env:NAME} placeholders using the variables in env instead
+// of the process environment.
+func WithEnvMap(env map[string]string) ReadOption {
+	return WithEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+}
+
+// WithStrictEnv makes a reference to an undefined environment variable without
+// a default value an error, instead of expanding it to an empty string.
+func WithStrictEnv() ReadOption {
+	return func(l *loader) {
+		l.strictEnv = true
+	}
+}
+
+// EnvReference describes a reference to an environment variable in the configuration.
+type EnvReference struct {
+	// Name is the name of the variable
+	Name string
+	// Default is the fallback value given in the placeholder
+	Default string
+	// HasDefault reports whether the placeholder gives a fallback value
+	HasDefault bool
+	// Defined reports whether the variable was defined while reading
+	Defined bool
+	// File is the file of the node containing the reference
+	File string
+	// Line is the line of the node containing the reference
+	Line int
+}
+
+// WithEnvReferences appends every reference to an environment variable in the
+// expanded configuration to refs, in order of appearance.
+func WithEnvReferences(refs *[]EnvReference) ReadOption {
+	return func(l *loader) {
+		l.envReferences = refs
+	}
+}
+
+// WithMacros predefines macros, as if each file started with a $(name) = values
+// declaration. Files can redefine them unless WithoutMacroRedefinition is given.
+// Like declared values, the values are subject to placeholder expansion.
+// Repeated options add to the predefined macros.
+func WithMacros(macros map[string][]string) ReadOption {
+	return func(l *loader) {
+		if l.predefinedMacros == nil {
+			l.predefinedMacros = make(map[string][]string, len(macros))
+		}
+		for name, values := range macros {
+			l.predefinedMacros[name] = append([]string{}, values...)
+		}
+	}
+}
+
+// WithoutMacroRedefinition makes declaring a macro predefined with WithMacros an error.
+func WithoutMacroRedefinition() ReadOption {
+	return func(l *loader) {
+		l.fixedMacros = true
+	}
+}
+
+// WithMacroTable stores the macros of the main file in table after a successful
+// read: the predefined macros, the macros it declares and those of the files it
+// imports. The values are those of the declarations, before placeholder expansion.
+func WithMacroTable(table *map[string][]string) ReadOption {
+	return func(l *loader) {
+		l.macroTable = table
+	}
+}
+
+// Sources lists what a configuration was read from.
+type Sources struct {
+	// Files holds the main file, the imported files, including files that were
+	// looked for but don't exist, and the files read by {file:...} placeholders
+	Files []string
+	// Patterns holds the glob patterns of imports
+	Patterns []string
+}
+
+// WithSources stores the files and patterns read in sources, even if reading fails.
+// Names are resolved like imports, but not made absolute. A Watcher uses them to
+// detect changes.
+func WithSources(sources *Sources) ReadOption {
+	return func(l *loader) {
+		l.sources = sources
+	}
+}
+
+// WithOrigins records in origins where the nodes of the configuration come from:
+// the snippets they are defined in, the imports of their files and the environment
+// variables they reference. See Origins.Of.
+func WithOrigins(origins *Origins) ReadOption {
+	return func(l *loader) {
+		l.origins = origins
+	}
+}
+
+// WithSecrets records in secrets the arguments of the configuration that contain
+// secret values, so that they can be redacted from dumps and error messages.
+// See Secrets.Redact and Secrets.RedactError.
+func WithSecrets(secrets *Secrets) ReadOption {
+	return func(l *loader) {
+		l.secrets = secrets
+	}
+}
+
+// WithResolver resolves {namespace:key} placeholders by calling resolver with key,
+// and {namespace} placeholders by calling it with an empty key. Placeholders of
+// namespaces without a resolver are left as is. It panics if namespace is invalid
+// or one of the built-in namespaces args, env and file.
+func WithResolver(namespace string, resolver Resolver) ReadOption {
+	return withResolver(namespace, resolverEntry{resolve: resolver})
+}
+
+// WithSecretResolver is like WithResolver, but the resolved values are secret,
+// see Secrets.
+func WithSecretResolver(namespace string, resolver Resolver) ReadOption {
+	return withResolver(namespace, resolverEntry{resolve: resolver, secret: true})
+}
+
+func withResolver(namespace string, entry resolverEntry) ReadOption {
+	checkNamespace(namespace)
+	return func(l *loader) {
+		if l.resolvers == nil {
+			l.resolvers = make(map[string]resolverEntry)
+		}
+		l.resolvers[namespace] = entry
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+)
+
+// Position is the location of a node in a configuration file.
+type Position struct {
+	File string
+	Line int
+}
+
+// String formats the position as file:line.
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// Origin describes where a node of a configuration came from.
+type Origin struct {
+	Position
+	// Snippet is the name of the snippet the node is defined in, if any
+	Snippet string
+	// ImportedAt lists the import directives through which the file of the node was
+	// imported, innermost first. For a file imported several times, the first import counts.
+	ImportedAt []Position
+	// Env lists the environment variables referenced by the arguments of the node
+	Env []string
+}
+
+// String describes the origin, e.g. "site.conf:14 via import common_tls, imported at app.conf:3".
+func (o Origin) String() string {
+	var sb strings.Builder
+	sb.WriteString(o.Position.String())
+	if o.Snippet != "" {
+		sb.WriteString(" via import ")
+		sb.WriteString(o.Snippet)
+	}
+	for i, p := range o.ImportedAt {
+		if i == 0 {
+			sb.WriteString(", imported at ")
+		} else {
+			sb.WriteString(" and ")
+		}
+		sb.WriteString(p.String())
+	}
+	if len(o.Env) != 0 {
+		sb.WriteString(", env ")
+		sb.WriteString(strings.Join(o.Env, " "))
+	}
+	return sb.String()
+}
+
+// Origins records where the nodes of a configuration come from, see WithOrigins.
+// Nodes are identified by their position, so all nodes at the same position share
+// their origin.
+type Origins struct {
+	// snippets maps the positions of nodes in snippet definitions to the snippet names
+	snippets map[Position]string
+	// imports maps imported files to the first import directive importing them
+	imports map[string]Position
+	// env maps node positions to the environment variables they reference
+	env map[Position][]string
+}
+
+// Of returns the origin of node, which must be part of the configuration read
+// with WithOrigins. A nil Origins only knows the position of the node.
+func (o *Origins) Of(node parser.Node) Origin {
+	pos := Position{File: node.File, Line: node.Line}
+	if o == nil {
+		return Origin{Position: pos}
+	}
+	origin := Origin{Position: pos, Snippet: o.snippets[pos], Env: slices.Clone(o.env[pos])}
+
+	seen := map[string]bool{}
+	for file := node.File; !seen[file]; {
+		seen[file] = true
+		at, ok := o.imports[file]
+		if !ok {
+			break
+		}
+		origin.ImportedAt = append(origin.ImportedAt, at)
+		file = at.File
+	}
+	return origin
+}
+
+// reset prepares o for recording a new read.
+func (o *Origins) reset() {
+	o.snippets = make(map[Position]string)
+	o.imports = make(map[string]Position)
+	o.env = make(map[Position][]string)
+}
+
+// addSnippet records the nodes of the snippet name.
+func (o *Origins) addSnippet(name string, list []parser.Node) {
+	for _, node := range list {
+		o.snippets[Position{File: node.File, Line: node.Line}] = name
+		o.addSnippet(name, node.Children)
+	}
+}
+
+// addImport records that file was imported by the import directive node,
+// unless it has been imported before.
+func (o *Origins) addImport(file string, node parser.Node) {
+	if _, ok := o.imports[file]; !ok {
+		o.imports[file] = Position{File: node.File, Line: node.Line}
+	}
+}
+
+// addEnv records that node references the environment variable name.
+func (o *Origins) addEnv(node parser.Node, name string) {
+	pos := Position{File: node.File, Line: node.Line}
+	if !slices.Contains(o.env[pos], name) {
+		o.env[pos] = append(o.env[pos], name)
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadWithOrigins(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf": {Data: []byte("log_level info\nimport site.conf\n")},
+		"site.conf": {Data: []byte("(common_tls) {\n  cert_file {env:TLS_DIR:/etc}/cert.pem\n}\n" +
+			"server web {\n  import common_tls\n  listen :80\n}\n")},
+	}
+
+	var origins Origins
+	ast, err := ReadFS(fsys, "app.conf", WithOrigins(&origins), WithEnvMap(nil))
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+
+	server := ast[1]
+	tests := []struct {
+		name string
+		got  Origin
+		want string
+	}{
+		{"top level", origins.Of(ast[0]), "app.conf:1"},
+		{"imported file", origins.Of(server), "site.conf:4, imported at app.conf:2"},
+		{"snippet", origins.Of(server.Children[0]), "site.conf:2 via import common_tls, imported at app.conf:2, env TLS_DIR"},
+		{"snippet user", origins.Of(server.Children[1]), "site.conf:6, imported at app.conf:2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.String(); got != tt.want {
+				t.Errorf("Origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilOrigins(t *testing.T) {
+	ast, err := ReadFS(fstest.MapFS{"app.conf": {Data: []byte("a\nb 1\n")}}, "app.conf")
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+
+	var origins *Origins
+	if got, want := origins.Of(ast[1]).String(), "app.conf:2"; got != want {
+		t.Errorf("Origin = %q, want %q", got, want)
+	}
+}
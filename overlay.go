@@ -0,0 +1,171 @@
+package config
+
+import (
+	"slices"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// DeleteDirective is the directive that deletes nodes when merging an overlay.
+// "delete name" deletes all nodes called name in the enclosing block,
+// "delete name args..." only those with exactly these arguments.
+const DeleteDirective = "delete"
+
+// MergeRules tell Merge which nodes may occur more than once.
+// schema.Builder implements MergeRules.
+type MergeRules interface {
+	// RepeatableAt reports whether the node at path may be repeated. path holds
+	// the names of the enclosing blocks followed by the name of the node.
+	RepeatableAt(path []string) bool
+}
+
+// Merge applies overlays to base in order and returns the merged tree.
+// Nodes are merged by their path:
+//
+//   - A directive replaces the directives of the same name, unless it is
+//     repeatable, in which case it is appended.
+//   - A block is merged into the block with the same name and arguments. A block
+//     that isn't repeatable is merged into the block of the same name, taking over
+//     its arguments. Other blocks are appended.
+//   - A delete directive deletes nodes, see DeleteDirective.
+//
+// If rules is nil, blocks are considered repeatable and directives are not.
+// base and overlays are not modified.
+func Merge(base AST, rules MergeRules, overlays ...AST) (AST, error) {
+	res := cloneNodes(base)
+	for _, overlay := range overlays {
+		var err error
+		res, err = mergeNodes(res, overlay, rules, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// ReadFileWithOverlays reads the file base and the overlay files and merges them
+// with Merge. Each file is read with ReadFile and the given options.
+func ReadFileWithOverlays(base string, overlays []string, rules MergeRules, options ...ReadOption) (AST, error) {
+	ast, err := ReadFile(base, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]AST, 0, len(overlays))
+	for _, file := range overlays {
+		overlay, err := ReadFile(file, options...)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, overlay)
+	}
+	return Merge(ast, rules, layers...)
+}
+
+// mergeNodes merges the overlay nodes into the nodes of the block at path.
+func mergeNodes(list, overlay []parser.Node, rules MergeRules, path []string) ([]parser.Node, error) {
+	for _, node := range overlay {
+		if node.Name == DeleteDirective && node.Children == nil {
+			if len(node.Args) == 0 {
+				return nil, nodes.NodeErr(node, "%s requires the name of the nodes to delete", DeleteDirective)
+			}
+			list = deleteNodes(list, node.Args)
+			continue
+		}
+
+		nodePath := append(path[:len(path):len(path)], node.Name)
+		repeatable := node.Children != nil
+		if rules != nil {
+			repeatable = rules.RepeatableAt(nodePath)
+		}
+
+		if node.Children == nil {
+			if repeatable {
+				list = append(list, cloneNode(node))
+			} else {
+				list = replaceDirective(list, node)
+			}
+			continue
+		}
+
+		i := slices.IndexFunc(list, func(n parser.Node) bool {
+			return n.Children != nil && n.Name == node.Name && slices.Equal(n.Args, node.Args)
+		})
+		if i == -1 && !repeatable {
+			i = slices.IndexFunc(list, func(n parser.Node) bool {
+				return n.Children != nil && n.Name == node.Name
+			})
+		}
+		if i == -1 {
+			// The block is merged into an empty one, so that its delete
+			// directives are applied rather than copied.
+			block := node
+			block.Args = slices.Clone(node.Args)
+			children, err := mergeNodes([]parser.Node{}, node.Children, rules, nodePath)
+			if err != nil {
+				return nil, err
+			}
+			block.Children = children
+			list = append(list, block)
+			continue
+		}
+
+		merged := list[i]
+		merged.Args = slices.Clone(node.Args)
+		children, err := mergeNodes(merged.Children, node.Children, rules, nodePath)
+		if err != nil {
+			return nil, err
+		}
+		merged.Children = children
+		list[i] = merged
+	}
+	return list, nil
+}
+
+// replaceDirective replaces the first directive named like node with node,
+// removing any others. node is appended if there is no such directive.
+func replaceDirective(list []parser.Node, node parser.Node) []parser.Node {
+	res := list[:0]
+	replaced := false
+	for _, n := range list {
+		if n.Children != nil || n.Name != node.Name {
+			res = append(res, n)
+			continue
+		}
+		if !replaced {
+			res = append(res, cloneNode(node))
+			replaced = true
+		}
+	}
+	if !replaced {
+		res = append(res, cloneNode(node))
+	}
+	return res
+}
+
+// deleteNodes removes the nodes selected by the arguments of a delete directive.
+func deleteNodes(list []parser.Node, args []string) []parser.Node {
+	return slices.DeleteFunc(list, func(n parser.Node) bool {
+		return n.Name == args[0] && (len(args) == 1 || slices.Equal(n.Args, args[1:]))
+	})
+}
+
+// cloneNodes returns a deep copy of list.
+func cloneNodes(list []parser.Node) []parser.Node {
+	if list == nil {
+		return nil
+	}
+	res := make([]parser.Node, 0, len(list))
+	for _, node := range list {
+		res = append(res, cloneNode(node))
+	}
+	return res
+}
+
+// cloneNode returns a deep copy of node.
+func cloneNode(node parser.Node) parser.Node {
+	node.Args = slices.Clone(node.Args)
+	node.Children = cloneNodes(node.Children)
+	return node
+}
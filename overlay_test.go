@@ -0,0 +1,205 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// repeatableRules are MergeRules listing the repeatable node paths.
+type repeatableRules []string
+
+func (r repeatableRules) RepeatableAt(path []string) bool {
+	return slices.Contains(r, strings.Join(path, "/"))
+}
+
+func TestMerge(t *testing.T) {
+	base := `log_level info
+hostname mx.example.org
+allow 10.0.0.0/8
+server web {
+    listen 80
+    root /srv/www
+}
+server api {
+    listen 8080
+}
+tls {
+    cert /etc/ssl/old.pem
+}
+`
+	rules := repeatableRules{"allow", "server", "server/listen"}
+
+	tests := []struct {
+		name    string
+		overlay string
+		rules   MergeRules
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "replace directive",
+			overlay: "log_level debug",
+			rules:   rules,
+			want:    strings.Replace(base, "log_level info", "log_level debug", 1),
+		},
+		{
+			name:    "append repeatable directive",
+			overlay: "allow 192.168.0.0/16",
+			rules:   rules,
+			want:    base + "allow 192.168.0.0/16\n",
+		},
+		{
+			name:    "add directive",
+			overlay: "timeout 30s",
+			rules:   rules,
+			want:    base + "timeout 30s\n",
+		},
+		{
+			name:    "merge block by arguments",
+			overlay: "server api {\n    listen 8443\n    root /srv/api\n}",
+			rules:   rules,
+			want:    strings.Replace(base, "    listen 8080\n", "    listen 8080\n    listen 8443\n    root /srv/api\n", 1),
+		},
+		{
+			name:    "append repeatable block",
+			overlay: "server admin {\n    listen 9000\n}",
+			rules:   rules,
+			want:    base + "server admin {\n    listen 9000\n}\n",
+		},
+		{
+			name:    "merge non-repeatable block by name",
+			overlay: "tls {\n    cert /etc/ssl/new.pem\n}",
+			rules:   rules,
+			want:    strings.Replace(base, "old.pem", "new.pem", 1),
+		},
+		{
+			name:    "delete by name",
+			overlay: "delete hostname\nserver web {\n    delete root\n}",
+			rules:   rules,
+			want:    strings.Replace(strings.Replace(base, "hostname mx.example.org\n", "", 1), "    root /srv/www\n", "", 1),
+		},
+		{
+			name:    "delete by arguments",
+			overlay: "delete server api",
+			rules:   rules,
+			want:    strings.Replace(base, "server api {\n    listen 8080\n}\n", "", 1),
+		},
+		{
+			name:    "delete within a new block",
+			overlay: "server b {\n    delete root\n    listen 1\n}\nempty {\n}",
+			rules:   rules,
+			want:    base + "server b {\n    listen 1\n}\nempty {\n}\n",
+		},
+		{
+			name:    "delete without name within a new block",
+			overlay: "server b {\n    delete\n}",
+			rules:   rules,
+			wantErr: "overlay.conf:2: delete requires the name of the nodes to delete",
+		},
+		{
+			name:    "delete and redefine",
+			overlay: "delete allow\nallow 127.0.0.1",
+			rules:   rules,
+			want:    strings.Replace(base, "allow 10.0.0.0/8\n", "", 1) + "allow 127.0.0.1\n",
+		},
+		{
+			name:    "nil rules",
+			overlay: "allow 127.0.0.1\ntls {\n    key /etc/ssl/key.pem\n}\nserver web2 {\n    listen 81\n}",
+			want: strings.Replace(strings.Replace(base, "allow 10.0.0.0/8", "allow 127.0.0.1", 1), "old.pem\n", "old.pem\n    key /etc/ssl/key.pem\n", 1) +
+				"server web2 {\n    listen 81\n}\n",
+		},
+		{
+			name:    "delete without name",
+			overlay: "a b\ndelete",
+			rules:   rules,
+			wantErr: "overlay.conf:2: delete requires the name of the nodes to delete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseAST, err := Read(strings.NewReader(base), "base.conf")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			overlay, err := Read(strings.NewReader(tt.overlay), "overlay.conf")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			merged, err := Merge(baseAST, tt.rules, overlay)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Merge() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+
+			got, err := Marshal(merged)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Merge() =\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			text, _ := Marshal(baseAST)
+			if string(text) != base {
+				t.Errorf("Merge() modified the base tree:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestMergeOrderAndPositions(t *testing.T) {
+	base := AST{{Name: "log_level", Args: []string{"info"}, File: "base.conf", Line: 1}}
+	first := AST{{Name: "log_level", Args: []string{"debug"}, File: "first.conf", Line: 3}}
+	second := AST{{Name: "log_level", Args: []string{"warn"}, File: "second.conf", Line: 7}}
+
+	merged, err := Merge(base, nil, first, second)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if len(merged) != 1 || merged[0].Args[0] != "warn" || merged[0].File != "second.conf" || merged[0].Line != 7 {
+		t.Errorf("Merge() = %+v, want the directive of the last overlay", merged)
+	}
+}
+
+func TestReadFileWithOverlays(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.conf":   "log_level info\nserver web {\n    listen 80\n}\n",
+		"local.conf":  "log_level debug\n",
+		"site.conf":   "server web {\n    listen 8080\n}\n",
+		"broken.conf": "server {\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ast, err := ReadFileWithOverlays(path("main.conf"), []string{path("local.conf"), path("site.conf")}, nil)
+	if err != nil {
+		t.Fatalf("ReadFileWithOverlays() error = %v", err)
+	}
+	got, err := Marshal(ast)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := "log_level debug\nserver web {\n    listen 8080\n}\n"; string(got) != want {
+		t.Errorf("ReadFileWithOverlays() =\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = ReadFileWithOverlays(path("main.conf"), []string{path("broken.conf")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken.conf") {
+		t.Errorf("ReadFileWithOverlays() error = %v, want an error for broken.conf", err)
+	}
+}
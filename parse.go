@@ -0,0 +1,481 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// loader holds the state shared by all files read by a single Read, ReadFile or ReadFS call.
+type loader struct {
+	// fsys locates and opens imported files
+	fsys importFS
+	// warningHandler receives non-fatal conditions, if set
+	warningHandler func(error)
+	// maxImportDepth limits the nesting of imported files, 0 means no limit
+	maxImportDepth int
+	// maxImportFiles limits the total number of imported files, 0 means no limit
+	maxImportFiles int
+	// importRoots restricts file imports to these directories, nil means no restriction
+	importRoots []string
+	// noFileImports rejects all file imports
+	noFileImports bool
+	// lookupEnv returns the value of an environment variable and whether it is defined
+	lookupEnv func(name string) (string, bool)
+	// strictEnv makes references to undefined environment variables an error
+	strictEnv bool
+	// envReferences collects references to environment variables, if set
+	envReferences *[]EnvReference
+	// resolvers resolve placeholders of application-defined namespaces
+	resolvers map[string]resolverEntry
+	// predefinedMacros are the macros defined before reading any file
+	predefinedMacros map[string][]string
+	// fixedMacros forbids redefining predefined macros
+	fixedMacros bool
+	// macroTable receives the macros of the main file after reading, if set
+	macroTable *map[string][]string
+	// sources collects the files and patterns read, if set
+	sources *Sources
+	// origins records where nodes come from, if set
+	origins *Origins
+	// secrets records the secret arguments, if set
+	secrets *Secrets
+	// secretResolved is set when a placeholder resolves to a secret value, so that
+	// the argument containing it can be recorded
+	secretResolved bool
+
+	// importChain lists the files currently being read, starting with the main file
+	importChain []string
+	// importCount is the number of files imported so far
+	importCount int
+}
+
+// newLoader creates a loader resolving imports through fsys.
+func newLoader(fsys importFS, options ...ReadOption) *loader {
+	l := &loader{fsys: fsys, lookupEnv: os.LookupEnv}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// warn reports a non-fatal condition to the warning handler.
+// It returns err if there is no handler, so that the condition fails the read.
+func (l *loader) warn(err error) error {
+	if l.warningHandler == nil {
+		return err
+	}
+	l.warningHandler(err)
+	return nil
+}
+
+// read parses the configuration read from r, including its imports, and expands placeholders.
+func (l *loader) read(r io.Reader, location string) (AST, error) {
+	for name := range l.predefinedMacros {
+		if name == "" || strings.ContainsAny(name, "$() \t\r\n") {
+			return nil, fmt.Errorf("invalid macro name %q", name)
+		}
+	}
+
+	if l.sources != nil {
+		*l.sources = Sources{}
+	}
+	if l.origins != nil {
+		l.origins.reset()
+	}
+	if l.secrets != nil {
+		l.secrets.reset()
+	}
+	l.addSourceFile(location)
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l.importChain = []string{location}
+	list, _, macros, err := l.readTree(src, location, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if l.macroTable != nil {
+		table := maps.Clone(l.predefinedMacros)
+		if table == nil {
+			table = make(map[string][]string, len(macros))
+		}
+		maps.Copy(table, macros)
+		*l.macroTable = table
+	}
+	return l.expandPlaceholders(list)
+}
+
+// addSourceFile records a file the configuration depends on.
+func (l *loader) addSourceFile(name string) {
+	if l.sources != nil && !slices.Contains(l.sources.Files, name) {
+		l.sources.Files = append(l.sources.Files, name)
+	}
+}
+
+// addSourcePattern records an import pattern the configuration depends on.
+func (l *loader) addSourcePattern(pattern string) {
+	if l.sources != nil && !slices.Contains(l.sources.Patterns, pattern) {
+		l.sources.Patterns = append(l.sources.Patterns, pattern)
+	}
+}
+
+// maxNesting limits the nesting of blocks and imports.
+const maxNesting = 256
+
+// parseContext holds the state used while parsing a single configuration file.
+// The parser reads a file line by line: a node takes the rest of its line, unless
+// an argument opens a block.
+type parseContext struct {
+	*loader
+	// location is the name of the file being parsed
+	location string
+	// lines are the lines of tokens of the file
+	lines []sourceLine
+	// line and col locate the next token, lines[line].tokens[col]
+	line, col int
+	// snippets maps snippet names to their bodies
+	snippets map[string][]parser.Node
+	// macros maps macro names to their (already expanded) values
+	macros map[string][]string
+	// declared holds the macros declared in the file and the files it imports,
+	// unlike macros without the predefined ones they don't redefine
+	declared map[string][]string
+	// recover makes parseFile collect errors and continue with the next top-level node
+	recover bool
+	// errs are the errors collected with recover
+	errs []error
+	// unterminated is the error of a quoted string that isn't closed, before
+	// which lines ends, see lexLines
+	unterminated error
+}
+
+// newParseContext prepares parsing src, read from location.
+func (l *loader) newParseContext(src []byte, location string) *parseContext {
+	lines, err := lexLines(src, location)
+	ctx := &parseContext{
+		loader:       l,
+		location:     location,
+		lines:        lines,
+		unterminated: err,
+		snippets:     make(map[string][]parser.Node),
+		macros:       maps.Clone(l.predefinedMacros),
+		declared:     make(map[string][]string),
+	}
+	if ctx.macros == nil {
+		ctx.macros = make(map[string][]string)
+	}
+	ctx.skipBlankLines()
+	return ctx
+}
+
+// more reports whether tokens are left.
+func (ctx *parseContext) more() bool {
+	return ctx.line < len(ctx.lines)
+}
+
+// atLineStart reports whether the next token is the first of its line, which
+// is also the case at the end of the file.
+func (ctx *parseContext) atLineStart() bool {
+	return ctx.col == 0
+}
+
+// peek returns the next token without consuming it.
+func (ctx *parseContext) peek() sourceToken {
+	return ctx.lines[ctx.line].tokens[ctx.col]
+}
+
+// next consumes the next token.
+func (ctx *parseContext) next() sourceToken {
+	token := ctx.peek()
+	ctx.col++
+	if ctx.col == len(ctx.lines[ctx.line].tokens) {
+		ctx.line, ctx.col = ctx.line+1, 0
+		ctx.skipBlankLines()
+	}
+	return token
+}
+
+// skipBlankLines moves to the next line holding tokens, skipping comment lines.
+func (ctx *parseContext) skipBlankLines() {
+	for ctx.more() && len(ctx.lines[ctx.line].tokens) == 0 {
+		ctx.line++
+	}
+}
+
+// errorAt returns an error at the position of token.
+func (ctx *parseContext) errorAt(token sourceToken, format string, args ...any) error {
+	return nodes.NodeErr(parser.Node{File: ctx.location, Line: token.line}, format, args...)
+}
+
+// parseFile parses the top-level nodes of the file. Snippet and macro
+// declarations are recorded rather than returned.
+func (ctx *parseContext) parseFile() ([]parser.Node, error) {
+	list := []parser.Node{}
+	for ctx.more() {
+		start := ctx.line
+		node, isDeclaration, err := ctx.parseTopLevel()
+		if err != nil {
+			if !ctx.recover {
+				return list, err
+			}
+			ctx.errs = append(ctx.errs, err)
+			ctx.skipToTopLevel(start)
+			continue
+		}
+		if !isDeclaration {
+			list = append(list, node)
+		}
+	}
+	// The error of an unterminated quoted string comes after the errors before
+	// it, unless a block left open by it has already reported it.
+	if ctx.unterminated != nil && !slices.Contains(ctx.errs, ctx.unterminated) {
+		if !ctx.recover {
+			return list, ctx.unterminated
+		}
+		ctx.errs = append(ctx.errs, ctx.unterminated)
+	}
+	return list, nil
+}
+
+// parseTopLevel parses a top-level node and records it if it is a declaration.
+func (ctx *parseContext) parseTopLevel() (parser.Node, bool, error) {
+	token := ctx.peek()
+	switch {
+	case !ctx.atLineStart():
+		return parser.Node{}, false, ctx.errorAt(token, `unexpected %s after "}", a closing brace must end its line`, token.value)
+	case token.value == "}":
+		ctx.next()
+		return parser.Node{}, false, ctx.errorAt(token, `unexpected "}" outside of a block`)
+	}
+
+	node, closes, err := ctx.parseNode(0)
+	if err != nil {
+		return node, false, err
+	}
+	if closes {
+		return node, false, nodes.NodeErr(node, `unexpected "}" outside of a block`)
+	}
+
+	switch {
+	case node.Macro:
+		return node, true, ctx.declareMacro(node)
+	case node.Snippet:
+		return node, true, ctx.declareSnippet(node)
+	}
+	return node, false, ctx.expandMacros(&node)
+}
+
+// declareMacro records the macro declaration node. Its values may refer to
+// macros declared before.
+func (ctx *parseContext) declareMacro(node parser.Node) error {
+	if _, ok := ctx.predefinedMacros[node.Name]; ok && ctx.fixedMacros {
+		return nodes.NodeErr(node, "predefined macro %s can't be redefined", node.Name)
+	}
+	if err := ctx.expandMacros(&node); err != nil {
+		return err
+	}
+	ctx.macros[node.Name] = node.Args
+	ctx.declared[node.Name] = node.Args
+	return nil
+}
+
+// declareSnippet records the snippet declaration node.
+func (ctx *parseContext) declareSnippet(node parser.Node) error {
+	if len(node.Args) != 0 {
+		return nodes.NodeErr(node, "snippet (%s) takes no arguments, they are passed to import", node.Name)
+	}
+	ctx.snippets[node.Name] = node.Children
+	if ctx.origins != nil {
+		ctx.origins.addSnippet(node.Name, node.Children)
+	}
+	return nil
+}
+
+// parseNode parses the node starting at the next token: its name, its arguments
+// up to the end of the line and the block opened by a "{" argument. A line ending
+// with a backslash is continued on the next one. closes reports whether the last
+// argument is "}", which closes the enclosing block. depth is the number of
+// enclosing blocks.
+func (ctx *parseContext) parseNode(depth int) (node parser.Node, closes bool, err error) {
+	name := ctx.next()
+	node = parser.Node{Name: name.value, File: ctx.location, Line: name.line}
+	if name.value == "{" {
+		return node, false, ctx.errorAt(name, `a block needs a name before "{"`)
+	}
+
+	continued := false
+	for ctx.more() && (continued || !ctx.atLineStart()) {
+		continued = false
+		token := ctx.next()
+		switch {
+		case token.value == "{":
+			node.Children, err = ctx.parseBlock(token, depth+1)
+			if err != nil {
+				return node, false, err
+			}
+		case token.value == `\` && ctx.atLineStart():
+			continued = true
+			continue
+		default:
+			node.Args = append(node.Args, token.value)
+			continue
+		}
+		break
+	}
+
+	if n := len(node.Args); n != 0 && node.Args[n-1] == "}" {
+		node.Args = node.Args[:n-1]
+		closes = true
+	}
+
+	switch {
+	case strings.HasPrefix(node.Name, "$("):
+		err = parseMacroDeclaration(&node)
+	case len(node.Name) >= 2 && node.Name[0] == '(' && node.Name[len(node.Name)-1] == ')':
+		node.Name = node.Name[1 : len(node.Name)-1]
+		node.Snippet = true
+	default:
+		err = checkNodeName(node.Name)
+		if err != nil {
+			err = nodes.NodeErr(node, "%w", err)
+		}
+	}
+	return node, closes, err
+}
+
+// parseMacroDeclaration turns node, read as "$(name) = values...", into the
+// declaration of macro name.
+func parseMacroDeclaration(node *parser.Node) error {
+	if !strings.HasSuffix(node.Name, ")") || len(node.Name) < 4 {
+		return nodes.NodeErr(*node, "invalid macro declaration %s, expected $(name) = value...", node.Name)
+	}
+	if len(node.Args) < 2 || node.Args[0] != "=" {
+		return nodes.NodeErr(*node, "macro declaration %s needs = followed by at least one value", node.Name)
+	}
+	node.Name = node.Name[2 : len(node.Name)-1]
+	node.Args = node.Args[1:]
+	node.Macro = true
+	return nil
+}
+
+// parseBlock parses the nodes of the block opened by the token open, up to the
+// closing brace. The first node may follow the opening brace on the same line.
+func (ctx *parseContext) parseBlock(open sourceToken, depth int) ([]parser.Node, error) {
+	// A non-nil slice distinguishes an empty block from a directive.
+	children := []parser.Node{}
+	if depth > maxNesting {
+		return children, ctx.errorAt(open, "blocks are nested more than %d levels deep", maxNesting)
+	}
+
+	for first := true; ; first = false {
+		if !ctx.more() {
+			if ctx.unterminated != nil {
+				return children, ctx.unterminated
+			}
+			return children, ctx.errorAt(open, `block is not closed, "}" expected before the end of the file`)
+		}
+		token := ctx.peek()
+		if !first && !ctx.atLineStart() {
+			return children, ctx.errorAt(token, `unexpected %s after "}", a closing brace must end its line`, token.value)
+		}
+		if token.value == "}" {
+			ctx.next()
+			return children, nil
+		}
+
+		node, closes, err := ctx.parseNode(depth)
+		if err != nil {
+			return children, err
+		}
+		switch {
+		case node.Macro:
+			return children, nodes.NodeErr(node, "macro $(%s) must be declared at the top level", node.Name)
+		case node.Snippet:
+			return children, nodes.NodeErr(node, "snippet (%s) must be declared at the top level", node.Name)
+		}
+		if err := ctx.expandMacros(&node); err != nil {
+			return children, err
+		}
+		children = append(children, node)
+		if closes {
+			return children, nil
+		}
+	}
+}
+
+// checkNodeName returns an error if name isn't a valid directive or block name:
+// letters, digits, '.', '-' and '_', not starting with a digit.
+func checkNodeName(name string) error {
+	if name == "" {
+		return errors.New("empty directive name")
+	}
+	for i, r := range name {
+		switch {
+		case i == 0 && unicode.IsDigit(r):
+			return fmt.Errorf("directive name %s starts with a digit", name)
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(".-_", r):
+			return fmt.Errorf("directive name %s contains %q, only letters, digits, '.', '-' and '_' are allowed", name, r)
+		}
+	}
+	return nil
+}
+
+// skipToTopLevel moves to the first line after the line with index start on which
+// a top-level node starts, as found by matching braces.
+func (ctx *parseContext) skipToTopLevel(start int) {
+	depth, continued := 0, false
+	for i, line := range ctx.lines {
+		if len(line.tokens) == 0 {
+			continue
+		}
+		if i > start && depth == 0 && !continued {
+			ctx.line, ctx.col = i, 0
+			return
+		}
+		for _, token := range line.tokens {
+			switch token.value {
+			case "{":
+				depth++
+			case "}":
+				depth = max(depth-1, 0)
+			}
+		}
+		continued = line.tokens[len(line.tokens)-1].value == `\`
+	}
+	ctx.line, ctx.col = len(ctx.lines), 0
+}
+
+// readTree parses a whole file and expands its imports. importer is the import
+// directive of imported files, whose arguments are substituted before the imports
+// and conditional blocks of the file are expanded, nil for the main file.
+// It also returns the snippets and macros declared in the file and the files it
+// imports, so that they can be made available to the importing file. Predefined
+// macros are only returned if they are redeclared.
+func (l *loader) readTree(src []byte, location string, expansionDepth int, importer *parser.Node) ([]parser.Node, map[string][]parser.Node, map[string][]string, error) {
+	ctx := l.newParseContext(src, location)
+	list, err := ctx.parseFile()
+	if err != nil {
+		return list, ctx.snippets, ctx.declared, err
+	}
+
+	if importer != nil {
+		list, err = substituteImportArgs(*importer, list, importer.Args[1:])
+		if err != nil {
+			return list, ctx.snippets, ctx.declared, err
+		}
+	}
+
+	list, err = ctx.expandImports(list, expansionDepth)
+	return list, ctx.snippets, ctx.declared, err
+}
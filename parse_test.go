@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadSyntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "directives", content: "a\nb 1 2\n\n# comment\nc \"x y\" # trailing\n", want: "a\nb 1 2\nc \"x y\"\n"},
+		{name: "block", content: "a b {\n    c 1\n}\n", want: "a b {\n    c 1\n}\n"},
+		{name: "empty block", content: "a {\n}\nb { }\n", want: "a {\n}\nb {\n}\n"},
+		{name: "one-line block", content: "a { b 1 }\nc\n", want: "a {\n    b 1\n}\nc\n"},
+		{name: "closing brace after the last directive", content: "a {\n    b {\n        c }\n}\n", want: "a {\n    b {\n        c\n    }\n}\n"},
+		{name: "continued line", content: "a 1 \\\n    2 \\\n    3\nb\n", want: "a 1 2 3\nb\n"},
+		{name: "continued block header", content: "a 1 \\\n{\n    b\n}\n", want: "a 1 {\n    b\n}\n"},
+		{name: "quoted token spanning lines", content: "a \"x\ny\" b\nc\n", want: "a \"x\ny\" b\nc\n"},
+		{name: "escaped quote", content: `a "x \"y\""` + "\n", want: `a "x \"y\""` + "\n"},
+		{name: "byte order mark", content: "\uFEFFa 1\n", want: "a 1\n"},
+		{name: "stray closing brace", content: "a\n}\n", wantErr: `test.conf:2: unexpected "}" outside of a block`},
+		{name: "closing brace not ending its line", content: "a {\n} b\n", wantErr: `test.conf:2: unexpected b after "}", a closing brace must end its line`},
+		{name: "unclosed block", content: "a {\n    b {\n    }\n", wantErr: `test.conf:1: block is not closed, "}" expected before the end of the file`},
+		{name: "block without name", content: "{\n}\n", wantErr: `test.conf:1: a block needs a name before "{"`},
+		{name: "invalid name", content: "a\nb/c 1\n", wantErr: "test.conf:2: directive name b/c contains '/', only letters, digits, '.', '-' and '_' are allowed"},
+		{name: "empty name", content: `"" 1`, wantErr: "test.conf:1: empty directive name"},
+		{name: "snippet with arguments", content: "(s) 1 {\n}\n", wantErr: "test.conf:1: snippet (s) takes no arguments, they are passed to import"},
+		{name: "invalid macro", content: "$(a) b\n", wantErr: "test.conf:1: macro declaration $(a) needs = followed by at least one value"},
+		{name: "nesting limit", content: strings.Repeat("a {\n", maxNesting+1), wantErr: "blocks are nested more than 256 levels deep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			text, err := Marshal(ast)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(text) != tt.want {
+				t.Errorf("Read() =\n%s\nwant\n%s", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPositions(t *testing.T) {
+	ast, err := Read(strings.NewReader("a \"x\ny\"\nb {\n    c\n}\nd \\\n    1\ne\n"), "test.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	lines := map[string]int{"a": 1, "b": 3, "d": 6, "e": 8}
+	for _, node := range ast {
+		if node.File != "test.conf" || node.Line != lines[node.Name] {
+			t.Errorf("node %s at %s:%d, want test.conf:%d", node.Name, node.File, node.Line, lines[node.Name])
+		}
+	}
+	if c := ast[1].Children[0]; c.Line != 4 {
+		t.Errorf("node c at line %d, want 4", c.Line)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+)
+
+// pathSegment is one element of a node path such as server[web]/listen.
+type pathSegment struct {
+	// name is the name of the node, or * for any node
+	name string
+	// args are the arguments the node must have, nil for any arguments
+	args []string
+}
+
+// parsePath parses a node path. A path consists of segments separated by
+// slashes, each a node name or * for any name, optionally followed by the
+// arguments of the node in brackets, separated by spaces, or [*] for any.
+// Slashes within brackets are part of the arguments, as in "location[/api]/root".
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var segments []pathSegment
+	for _, s := range splitPath(path) {
+		var segment pathSegment
+		name, rest, hasArgs := strings.Cut(s, "[")
+		segment.name = name
+		if hasArgs {
+			args, ok := strings.CutSuffix(rest, "]")
+			if !ok || strings.ContainsAny(args, "[]") {
+				return nil, fmt.Errorf("invalid path segment %q: unbalanced brackets", s)
+			}
+			if args != "*" {
+				segment.args = strings.Fields(args)
+			}
+		}
+
+		if name != "*" {
+			if err := checkNodeName(name); err != nil {
+				return nil, fmt.Errorf("invalid path segment %q: %v", s, err)
+			}
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// splitPath splits a node path at the slashes outside of brackets, which may
+// be part of block arguments.
+func splitPath(path string) []string {
+	var res []string
+	depth, start := 0, 0
+	for i, c := range path {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '/':
+			if depth == 0 {
+				res = append(res, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, path[start:])
+}
+
+// Query returns the nodes at path, in the order they appear, with their positions.
+// A path consists of node names separated by slashes, e.g. "server[web]/tls/cert_file".
+// A name can be * for any node and be followed by the arguments of the node in
+// brackets, separated by spaces, or by [*] for any arguments, which is the default.
+// So "server[*]/listen" and "server/listen" both match the listen directives of all
+// servers, while "tls[]" only matches tls blocks without arguments. Slashes within
+// brackets are part of the arguments, so the paths reported by Diff can be queried.
+func (a AST) Query(path string) ([]parser.Node, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return matchPath(a, segments), nil
+}
+
+// matches reports whether node matches the segment.
+func (s pathSegment) matches(node parser.Node) bool {
+	if s.name != "*" && s.name != node.Name {
+		return false
+	}
+	return s.args == nil || slices.Equal(s.args, node.Args)
+}
+
+// matchPath returns the nodes in list matching path, in the order they appear.
+func matchPath(list []parser.Node, path []pathSegment) []parser.Node {
+	var res []parser.Node
+	for _, node := range list {
+		if !path[0].matches(node) {
+			continue
+		}
+		if len(path) == 1 {
+			res = append(res, node)
+			continue
+		}
+		res = append(res, matchPath(node.Children, path[1:])...)
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []pathSegment
+		wantErr bool
+	}{
+		{path: "log_level", want: []pathSegment{{name: "log_level"}}},
+		{path: "server[web]/tls/cert_file", want: []pathSegment{{name: "server", args: []string{"web"}}, {name: "tls"}, {name: "cert_file"}}},
+		{path: "server[*]/listen", want: []pathSegment{{name: "server"}, {name: "listen"}}},
+		{path: "*/listen", want: []pathSegment{{name: "*"}, {name: "listen"}}},
+		{path: "site[example.org 443]", want: []pathSegment{{name: "site", args: []string{"example.org", "443"}}}},
+		{path: "tls[]", want: []pathSegment{{name: "tls", args: []string{}}}},
+		{path: "location[/api/v1]/root", want: []pathSegment{{name: "location", args: []string{"/api/v1"}}, {name: "root"}}},
+		{path: "", wantErr: true},
+		{path: "server/", wantErr: true},
+		{path: "server[web", wantErr: true},
+		{path: "server[w[e]b]", wantErr: true},
+		{path: "server[web]x", wantErr: true},
+		{path: "1server", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parsePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePath() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ast, err := Read(strings.NewReader(`log_level info
+server web {
+  listen :80
+  tls {
+    cert_file web.pem
+  }
+}
+server api v1 {
+  listen :81
+  listen :82
+}
+location /api {
+  root /srv/api
+}
+`), "app.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "log_level", want: []string{"app.conf:1 info"}},
+		{path: "server[web]/tls/cert_file", want: []string{"app.conf:5 web.pem"}},
+		{path: "server[*]/listen", want: []string{"app.conf:3 :80", "app.conf:9 :81", "app.conf:10 :82"}},
+		{path: "server[api v1]/listen", want: []string{"app.conf:9 :81", "app.conf:10 :82"}},
+		{path: "*/tls", want: []string{"app.conf:4 "}},
+		{path: "server[api]/listen"},
+		{path: "location[/api]/root", want: []string{"app.conf:13 /srv/api"}},
+		{path: "unknown"},
+		{path: "server[", wantErr: true},
+	}
+
+	// Paths in the format of JoinPath, as used by Diff, can be queried.
+	location := ast[len(ast)-1]
+	path := nodes.JoinPath(nodes.JoinPath("", location), location.Children[0])
+	if got, err := ast.Query(path); err != nil || len(got) != 1 || got[0].Line != 13 {
+		t.Errorf("Query(%q) = %v, %v, want the root directive", path, got, err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			nodes, err := ast.Query(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, node := range nodes {
+				got = append(got, fmt.Sprintf("%s:%d %s", node.File, node.Line, strings.Join(node.Args, " ")))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// equalNodes reports whether two lists hold the same nodes, ignoring their positions.
+func equalNodes(a, b []parser.Node) bool {
+	return slices.EqualFunc(a, b, func(x, y parser.Node) bool {
+		return x.Name == y.Name && slices.Equal(x.Args, y.Args) &&
+			(x.Children == nil) == (y.Children == nil) && equalNodes(x.Children, y.Children)
+	})
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// placeholderRe matches a placeholder such as {env:NAME} or a macro reference,
+// each with an optional escaping backslash.
+var placeholderRe = regexp.MustCompile(`\\?(?:\{[A-Za-z_][A-Za-z0-9_.\-]*(?:\[[^\]{}]*\])?(?::[^{}]*)?\}|\$\([^$()]+\))`)
+
+// expandPlaceholders replaces placeholders in node names and arguments and
+// resolves escape sequences, in list and all blocks it contains. It is the last
+// expansion step, so values it substitutes are never expanded again. list is
+// left unchanged.
+func (l *loader) expandPlaceholders(list []parser.Node) ([]parser.Node, error) {
+	// Cloning keeps a nil list, which marks a directive rather than an empty block.
+	expanded := slices.Clone(list)
+	for i, node := range list {
+		name, err := l.replacePlaceholders(node, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		args, err := l.expandArgs(node)
+		if err != nil {
+			return nil, err
+		}
+		children, err := l.expandPlaceholders(node.Children)
+		if err != nil {
+			return nil, err
+		}
+		expanded[i].Name, expanded[i].Args, expanded[i].Children = name, args, children
+	}
+	return expanded, nil
+}
+
+// expandArgs expands the arguments of node.
+func (l *loader) expandArgs(node parser.Node) ([]string, error) {
+	args := make([]string, 0, len(node.Args))
+	for _, arg := range node.Args {
+		arg, err := l.expandArg(node, arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// expandArg expands a single argument of node. A lone escaped brace stands for
+// a literal brace, which would otherwise open or close a block.
+func (l *loader) expandArg(node parser.Node, arg string) (string, error) {
+	switch arg {
+	case `\{`:
+		return "{", nil
+	case `\}`:
+		return "}", nil
+	}
+
+	l.secretResolved = false
+	arg, err := l.replacePlaceholders(node, arg)
+	if l.secretResolved && l.secrets != nil {
+		l.secrets.add(arg)
+	}
+	return arg, err
+}
+
+// replacePlaceholders substitutes known placeholders in s, which belongs to node,
+// and unescapes escaped placeholders and macro references. Unknown placeholders
+// are left as is.
+func (l *loader) replacePlaceholders(node parser.Node, s string) (string, error) {
+	var err error
+	s = placeholderRe.ReplaceAllStringFunc(s, func(p string) string {
+		if p[0] == '\\' {
+			return p[1:]
+		}
+		if p[0] != '{' || err != nil {
+			return p
+		}
+
+		value, ok, lookupErr := l.lookupPlaceholder(node, p[1:len(p)-1])
+		if lookupErr != nil {
+			err = lookupErr
+		}
+		if !ok {
+			return p
+		}
+		return value
+	})
+	return s, err
+}
+
+// lookupPlaceholder resolves the placeholder key (the text between the braces)
+// found in node. It reports false for unknown placeholders.
+func (l *loader) lookupPlaceholder(node parser.Node, key string) (string, bool, error) {
+	// Import arguments are substituted when importing, any left are misplaced.
+	if strings.HasPrefix(key, "args[") {
+		return "", true, nodes.NodeErr(node, "{%s} can only be used in imported snippets and files", key)
+	}
+
+	namespace, arg, hasArg := strings.Cut(key, ":")
+
+	switch namespace {
+	case "env":
+		if !hasArg {
+			return "", false, nil
+		}
+		value, err := l.lookupEnvPlaceholder(node, arg)
+		return value, true, err
+	case "file":
+		if !hasArg {
+			return "", false, nil
+		}
+		value, err := l.readFilePlaceholder(node, arg)
+		return value, true, err
+	}
+	return l.resolveNamespace(node, key, namespace, arg)
+}
+
+// lookupEnvPlaceholder resolves an environment placeholder given as NAME or NAME:default.
+// Undefined variables expand to the default, or to an empty string in non-strict mode.
+func (l *loader) lookupEnvPlaceholder(node parser.Node, spec string) (string, error) {
+	name, def, hasDefault := strings.Cut(spec, ":")
+	value, defined := l.lookupEnv(name)
+
+	if l.envReferences != nil {
+		*l.envReferences = append(*l.envReferences, EnvReference{
+			Name:       name,
+			Default:    def,
+			HasDefault: hasDefault,
+			Defined:    defined,
+			File:       node.File,
+			Line:       node.Line,
+		})
+	}
+	if l.origins != nil {
+		l.origins.addEnv(node, name)
+	}
+
+	switch {
+	case defined:
+		return value, nil
+	case hasDefault:
+		return def, nil
+	case l.strictEnv:
+		return "", nodes.NodeErr(node, "undefined environment variable %s", name)
+	}
+	return "", nil
+}
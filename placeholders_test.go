@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readArgs reads content and returns the arguments of its first node.
+func readArgs(t *testing.T, content string, options ...ReadOption) []string {
+	t.Helper()
+
+	ast, err := Read(strings.NewReader(content), "test.conf", options...)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(ast) == 0 {
+		t.Fatal("Read() returned empty AST")
+	}
+	return ast[0].Args
+}
+
+func TestReadWithEnv(t *testing.T) {
+	t.Setenv("TEST_PROCESS_VAR", "from-process")
+
+	content := "listen {env:HOST}:{env:PORT} {env:TEST_PROCESS_VAR}"
+
+	tests := []struct {
+		name    string
+		options []ReadOption
+		want    []string
+	}{
+		{
+			name: "process environment",
+			want: []string{":", "from-process"},
+		},
+		{
+			name:    "map",
+			options: []ReadOption{WithEnvMap(map[string]string{"HOST": "localhost", "PORT": "25"})},
+			want:    []string{"localhost:25", ""},
+		},
+		{
+			name: "lookup function",
+			options: []ReadOption{WithEnv(func(name string) (string, bool) {
+				return strings.ToLower(name), true
+			})},
+			want: []string{"host:port", "test_process_var"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readArgs(t, content, tt.options...)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Read() args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWithEnvConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(tenant string) {
+			defer wg.Done()
+
+			env := WithEnvMap(map[string]string{"TENANT": tenant})
+			ast, err := Read(strings.NewReader("name {env:TENANT}"), tenant+".conf", env)
+			if err != nil {
+				t.Errorf("Read() error = %v", err)
+				return
+			}
+			if ast[0].Args[0] != tenant {
+				t.Errorf("Read() expanded {env:TENANT} to %q, want %q", ast[0].Args[0], tenant)
+			}
+		}(fmt.Sprintf("tenant%d", i))
+	}
+	wg.Wait()
+}
+
+func TestReadEnvDefault(t *testing.T) {
+	env := WithEnvMap(map[string]string{"SET": "value", "EMPTY": ""})
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "undefined", content: "a {env:UNSET:fallback}", want: []string{"fallback"}},
+		{name: "defined", content: "a {env:SET:fallback}", want: []string{"value"}},
+		{name: "defined empty", content: "a {env:EMPTY:fallback}", want: []string{""}},
+		{name: "empty default", content: "a {env:UNSET:}", want: []string{""}},
+		{name: "default with colon", content: "a {env:UNSET:localhost:25}", want: []string{"localhost:25"}},
+		{name: "default with spaces", content: `a "{env:UNSET:a b}"`, want: []string{"a b"}},
+		{name: "inline", content: "a http://{env:UNSET:localhost}/", want: []string{"http://localhost/"}},
+		{name: "escaped", content: `a \{env:UNSET:fallback}`, want: []string{"{env:UNSET:fallback}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readArgs(t, tt.content, env)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Read() args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadStrictEnv(t *testing.T) {
+	env := WithEnvMap(map[string]string{"SET": "value"})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "defined", content: "a {env:SET}"},
+		{name: "default", content: "a {env:UNSET:x}"},
+		{name: "undefined", content: "a b\nc {env:UNSET}", wantErr: "test.conf:2: undefined environment variable UNSET"},
+		{name: "undefined in block", content: "a {\n    b {env:UNSET}\n}", wantErr: "test.conf:2: undefined environment variable UNSET"},
+		{name: "escaped", content: `a \{env:UNSET}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.content), "test.conf", env, WithStrictEnv())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Read() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadEnvReferences(t *testing.T) {
+	content := `(snip) {
+    port {env:PORT:25}
+}
+host {env:HOST}
+server {
+    import snip
+    unused \{env:ESCAPED}
+}`
+
+	var refs []EnvReference
+	_, err := Read(strings.NewReader(content), "test.conf",
+		WithEnvMap(map[string]string{"HOST": "localhost"}), WithEnvReferences(&refs))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	want := []EnvReference{
+		{Name: "HOST", Defined: true, File: "test.conf", Line: 4},
+		{Name: "PORT", Default: "25", HasDefault: true, File: "test.conf", Line: 2},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("Read() references = %+v, want %+v", refs, want)
+	}
+}
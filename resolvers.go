@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// Resolver returns the value of a placeholder {namespace:key} for key, or of a
+// placeholder {namespace} for an empty key. A returned error fails the read and
+// is reported at the position of the placeholder.
+type Resolver func(key string) (string, error)
+
+// resolverEntry is a resolver registered for a placeholder namespace.
+type resolverEntry struct {
+	resolve Resolver
+	// secret makes the arguments containing resolved values secret
+	secret bool
+}
+
+// builtinNamespaces are the placeholder namespaces resolved by the loader itself.
+var builtinNamespaces = map[string]bool{
+	"args": true,
+	"env":  true,
+	"file": true,
+}
+
+// namespaceRe matches the names of placeholder namespaces.
+var namespaceRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+var (
+	registryMu sync.RWMutex
+	// registry holds the resolvers registered for all reads
+	registry = make(map[string]resolverEntry)
+)
+
+// RegisterResolver registers resolver for the placeholder namespace in all reads,
+// e.g. from the init function of a plugin. Resolvers passed to WithResolver take
+// precedence. It panics if namespace is invalid, built in or already registered.
+func RegisterResolver(namespace string, resolver Resolver) {
+	register(namespace, resolverEntry{resolve: resolver})
+}
+
+// RegisterSecretResolver is like RegisterResolver, but the resolved values are
+// secret, see Secrets.
+func RegisterSecretResolver(namespace string, resolver Resolver) {
+	register(namespace, resolverEntry{resolve: resolver, secret: true})
+}
+
+func register(namespace string, entry resolverEntry) {
+	checkNamespace(namespace)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[namespace]; ok {
+		panic(fmt.Sprintf("placeholder namespace '%s' is already registered", namespace))
+	}
+	registry[namespace] = entry
+}
+
+// unregisterResolver removes the resolver registered for namespace, e.g. so that
+// tests can register it again.
+func unregisterResolver(namespace string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, namespace)
+}
+
+// checkNamespace panics if resolvers can't be registered for namespace.
+func checkNamespace(namespace string) {
+	if !namespaceRe.MatchString(namespace) {
+		panic(fmt.Sprintf("invalid placeholder namespace '%s'", namespace))
+	}
+	if builtinNamespaces[namespace] {
+		panic(fmt.Sprintf("placeholder namespace '%s' is built in", namespace))
+	}
+}
+
+// MapResolver returns a Resolver looking keys up in values, e.g. as a local
+// stand-in for a secret store. Unknown keys are an error.
+func MapResolver(values map[string]string) Resolver {
+	return func(key string) (string, error) {
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("unknown key %s", key)
+		}
+		return value, nil
+	}
+}
+
+// resolveNamespace resolves the placeholder {placeholder} in node using the
+// resolver for namespace. It reports false if no resolver is registered for namespace.
+func (l *loader) resolveNamespace(node parser.Node, placeholder, namespace, key string) (string, bool, error) {
+	r, ok := l.resolvers[namespace]
+	if !ok {
+		registryMu.RLock()
+		r, ok = registry[namespace]
+		registryMu.RUnlock()
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	value, err := r.resolve(key)
+	if err != nil {
+		return "", true, nodes.NodeErr(node, "resolving {%s}: %v", placeholder, err)
+	}
+	if r.secret {
+		l.secretResolved = true
+	}
+	return value, true, nil
+}
+
+// readFilePlaceholder returns the contents of the file named by a {file:name}
+// placeholder in node, without leading and trailing whitespace. Relative names
+// are resolved against the file containing node. The contents are secret, see Secrets.
+func (l *loader) readFilePlaceholder(node parser.Node, name string) (string, error) {
+	if l.noFileImports {
+		return "", nodes.NodeErr(node, "file placeholders are disabled")
+	}
+
+	file := l.fsys.resolve(node.File, name)
+	l.addSourceFile(file)
+	ok, err := l.allowedFile(file)
+	if err != nil {
+		return "", nodes.NodeErr(node, "%v", err)
+	}
+	if !ok {
+		return "", nodes.NodeErr(node, "file %s is outside the allowed directories", file)
+	}
+
+	f, err := l.fsys.open(file)
+	if err != nil {
+		return "", nodes.NodeErr(node, "resolving {file:%s}: %v", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", nodes.NodeErr(node, "resolving {file:%s}: %v", name, err)
+	}
+
+	l.secretResolved = true
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadFilePlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("s3cret-file-value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "conf"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		options []ReadOption
+		want    string
+		wantErr string
+	}{
+		{name: "absolute", content: "password {file:" + filepath.Join(dir, "db_password") + "}", want: "s3cret-file-value"},
+		{name: "relative", content: "password {file:../db_password}", want: "s3cret-file-value"},
+		{name: "inline", content: "dsn postgres://app:{file:../db_password}@db/app", want: "postgres://app:s3cret-file-value@db/app"},
+		{name: "escaped", content: `password \{file:../db_password}`, want: "{file:../db_password}"},
+		{name: "missing", content: "password {file:missing}", wantErr: "app.conf:1: resolving {file:missing}"},
+		{name: "disabled", content: "password {file:../db_password}", options: []ReadOption{WithoutFileImports()}, wantErr: "app.conf:1: file placeholders are disabled"},
+		{name: "outside roots", content: "password {file:../db_password}", options: []ReadOption{WithImportRoots(filepath.Join(dir, "conf"))}, wantErr: "is outside the allowed directories"},
+		{name: "within roots", content: "password {file:../db_password}", options: []ReadOption{WithImportRoots(dir)}, want: "s3cret-file-value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secrets Secrets
+			options := append([]ReadOption{WithSecrets(&secrets)}, tt.options...)
+			ast, err := Read(strings.NewReader(tt.content), filepath.Join(dir, "conf", "app.conf"), options...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if ast[0].Args[0] != tt.want {
+				t.Errorf("Read() arg = %q, want %q", ast[0].Args[0], tt.want)
+			}
+			if secret := tt.name != "escaped"; secrets.Contains(tt.want) != secret {
+				t.Errorf("Secrets.Contains(%q) = %v, want %v", tt.want, !secret, secret)
+			}
+		})
+	}
+}
+
+func TestReadFSFilePlaceholder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/app.conf": {Data: []byte("a {file:token}\nb {file:/secrets/key}")},
+		"conf/token":    {Data: []byte("  fs-token-value  ")},
+		"secrets/key":   {Data: []byte("fs-key-value")},
+	}
+
+	ast, err := ReadFS(fsys, "conf/app.conf")
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+	if got := ast[0].Args[0] + " " + ast[1].Args[0]; got != "fs-token-value fs-key-value" {
+		t.Errorf("ReadFS() args = %q, want %q", got, "fs-token-value fs-key-value")
+	}
+}
+
+func TestReadWithResolver(t *testing.T) {
+	vault := MapResolver(map[string]string{"db/password": "vault-value"})
+	failing := func(key string) (string, error) {
+		return "", errors.New("connection refused")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		options []ReadOption
+		want    string
+		wantErr string
+	}{
+		{name: "resolved", content: "a {vault:db/password}", options: []ReadOption{WithResolver("vault", vault)}, want: "vault-value"},
+		{name: "no resolver", content: "a {vault:db/password}", want: "{vault:db/password}"},
+		{name: "unknown key", content: "a b\nc {vault:missing}", options: []ReadOption{WithResolver("vault", vault)}, wantErr: "test.conf:2: resolving {vault:missing}: unknown key missing"},
+		{name: "failing", content: "a {vault:x}", options: []ReadOption{WithResolver("vault", failing)}, wantErr: "test.conf:1: resolving {vault:x}: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secrets Secrets
+			options := append([]ReadOption{WithSecrets(&secrets)}, tt.options...)
+			ast, err := Read(strings.NewReader(tt.content), "test.conf", options...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if ast[0].Args[0] != tt.want {
+				t.Errorf("Read() arg = %q, want %q", ast[0].Args[0], tt.want)
+			}
+			if secrets.Contains(tt.want) {
+				t.Errorf("Secrets.Contains(%q) = true, values of WithResolver are not secret", tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWithSecretResolver(t *testing.T) {
+	var secrets Secrets
+	secret := WithSecretResolver("secret", MapResolver(map[string]string{"api_key": "resolver-secret-value"}))
+	ast, err := Read(strings.NewReader("api_key {secret:api_key}"), "test.conf", secret, WithSecrets(&secrets))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if ast[0].Args[0] != "resolver-secret-value" {
+		t.Errorf("Read() arg = %q, want %q", ast[0].Args[0], "resolver-secret-value")
+	}
+	if !secrets.Contains("resolver-secret-value") {
+		t.Error("values of WithSecretResolver should be marked as secrets")
+	}
+}
+
+func TestWithResolverBuiltinNamespace(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithResolver() should panic for a built-in namespace")
+		}
+	}()
+	WithResolver("env", MapResolver(nil))
+}
+
+func TestRegisterResolver(t *testing.T) {
+	t.Cleanup(func() {
+		unregisterResolver("test_hostname")
+		unregisterResolver("test_system")
+	})
+	RegisterResolver("test_hostname", func(key string) (string, error) {
+		if key != "" {
+			return "", errors.New("unexpected key")
+		}
+		return "host.example.org", nil
+	})
+	RegisterResolver("test_system", func(key string) (string, error) {
+		if key == "cpus" {
+			return "4", nil
+		}
+		return "", fmt.Errorf("unknown system value %s", key)
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		options []ReadOption
+		want    []string
+		wantErr string
+	}{
+		{name: "without key", content: "a {test_hostname}", want: []string{"host.example.org"}},
+		{name: "with key", content: "a {test_system:cpus} workers-{test_system:cpus}", want: []string{"4", "workers-4"}},
+		{name: "unregistered", content: "a {test_unregistered} {test_unregistered:x}", want: []string{"{test_unregistered}", "{test_unregistered:x}"}},
+		{name: "builtin without key", content: "a {env} {file}", want: []string{"{env}", "{file}"}},
+		{
+			name:    "read option takes precedence",
+			content: "a {test_hostname}",
+			options: []ReadOption{WithResolver("test_hostname", MapResolver(map[string]string{"": "override"}))},
+			want:    []string{"override"},
+		},
+		{name: "error without key", content: "a b\nc {test_hostname:x}", wantErr: "test.conf:2: resolving {test_hostname:x}: unexpected key"},
+		{name: "error with key", content: "a {test_system:ram}", wantErr: "test.conf:1: resolving {test_system:ram}: unknown system value ram"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Read(strings.NewReader(tt.content), "test.conf", tt.options...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if strings.Join(ast[0].Args, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Read() args = %q, want %q", ast[0].Args, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterResolverPanics(t *testing.T) {
+	t.Cleanup(func() { unregisterResolver("test_duplicate") })
+	RegisterResolver("test_duplicate", MapResolver(nil))
+
+	tests := []struct {
+		name      string
+		namespace string
+	}{
+		{name: "duplicate", namespace: "test_duplicate"},
+		{name: "builtin", namespace: "file"},
+		{name: "invalid", namespace: "1ns"},
+		{name: "empty", namespace: ""},
+		{name: "colon", namespace: "a:b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterResolver(%q) should panic", tt.namespace)
+				}
+			}()
+			RegisterResolver(tt.namespace, MapResolver(nil))
+		})
+	}
+}
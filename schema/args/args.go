@@ -26,21 +26,26 @@ type ArgDef struct {
 	name string
 	// target is the value that will store the argument's parsed value
 	target values.Value
+	// defValue is the string representation of the target before any value is set
+	defValue string
 	// valType defines the type of the argument
 	valType ValueType
 	// required indicates whether the argument must be provided
 	required bool
 	// variadic indicates if the argument can accept multiple values
 	variadic bool
+	// doc describes the argument
+	doc string
 }
 
 // NewArgDef creates a new argument definition.
-// target is the value that will store the parsed argument.
+// target is the value that will store the parsed argument, its current value is recorded as the default.
 // t is the type of the argument.
 // attributes can modify the argument's behavior (e.g., Optional).
 func NewArgDef(target values.Value, t ValueType, attributes ...ArgAttribute) *ArgDef {
 	arg := &ArgDef{
 		target:   target,
+		defValue: target.String(),
 		valType:  t,
 		required: true,
 	}
@@ -61,6 +66,25 @@ func NewVariadicArgDef(target values.Value, t ValueType, attributes ...ArgAttrib
 	return arg
 }
 
+// SetName sets the name of the argument, which is used in error messages and documentation.
+// It returns the argument definition for method chaining.
+func (d *ArgDef) SetName(name string) *ArgDef {
+	d.name = name
+	return d
+}
+
+// Doc sets the description of the argument.
+// It returns the argument definition for method chaining.
+func (d *ArgDef) Doc(text string) *ArgDef {
+	d.doc = text
+	return d
+}
+
+// Documentation returns the description of the argument.
+func (d *ArgDef) Documentation() string {
+	return d.doc
+}
+
 // Name returns the name of the argument.
 func (d *ArgDef) Name() string {
 	return d.name
@@ -85,3 +109,9 @@ func (d *ArgDef) Variadic() bool {
 func (d *ArgDef) Target() values.Value {
 	return d.target
 }
+
+// DefValue returns the string representation of the target's value at the time
+// the argument was defined.
+func (d *ArgDef) DefValue() string {
+	return d.defValue
+}
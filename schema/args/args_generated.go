@@ -1,6 +1,10 @@
 package args
 
-import "github.com/open-webtech/go-xaddy-config/schema/values"
+import (
+	"strconv"
+
+	"github.com/open-webtech/go-xaddy-config/schema/values"
+)
 
 // This file is autogenerated using "go generate ./schema/args". Do not modify, your changes will be lost.
 
@@ -13,6 +17,43 @@ const (
 	Float64
 )
 
+// String returns the name of the value type, as used in Go.
+func (t ValueType) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case Uint:
+		return "uint"
+	case Int:
+		return "int"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	}
+	return "ValueType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Help describes the values accepted for the value type, e.g. "a number".
+// It returns "" for types accepting any value.
+func (t ValueType) Help() string {
+	switch t {
+	case Bool:
+		return "a boolean (true or false)"
+	case Uint:
+		return "a non-negative integer"
+	case Int:
+		return "a number"
+	case Float32:
+		return "a number"
+	case Float64:
+		return "a number"
+	}
+	return ""
+}
+
 func BoolArg(target *bool, attributes ...ArgAttribute) *ArgDef {
 	return NewArgDef(values.NewBoolValue(target), Bool, attributes...)
 }
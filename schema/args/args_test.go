@@ -243,4 +243,54 @@ func TestBoolArgWithInvalidValues(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error when setting invalid boolean value")
 	}
-}
\ No newline at end of file
+}
+
+func TestArgDefDefValue(t *testing.T) {
+	port := 25
+	argDef := IntArg(&port)
+
+	if argDef.DefValue() != "25" {
+		t.Errorf("Expected DefValue '25', got '%s'", argDef.DefValue())
+	}
+
+	// Changing the target must not change the recorded default
+	argDef.Target().Set("587")
+	if argDef.DefValue() != "25" {
+		t.Errorf("Expected DefValue to remain '25', got '%s'", argDef.DefValue())
+	}
+	if argDef.Target().String() != "587" {
+		t.Errorf("Expected target value '587', got '%s'", argDef.Target().String())
+	}
+}
+
+func TestValueTypeString(t *testing.T) {
+	tests := map[ValueType]string{
+		Bool:          "bool",
+		String:        "string",
+		Uint:          "uint",
+		Int:           "int",
+		Float32:       "float32",
+		Float64:       "float64",
+		ValueType(42): "ValueType(42)",
+	}
+	for typ, want := range tests {
+		if got := typ.String(); got != want {
+			t.Errorf("ValueType(%d).String() = %q, want %q", int(typ), got, want)
+		}
+	}
+}
+
+func TestArgDefDocumentation(t *testing.T) {
+	var port int
+	arg := IntArg(&port).SetName("port").Doc("The TCP port.")
+
+	if arg.Name() != "port" {
+		t.Errorf("Name() = %q, want %q", arg.Name(), "port")
+	}
+	if arg.Documentation() != "The TCP port." {
+		t.Errorf("Documentation() = %q, want %q", arg.Documentation(), "The TCP port.")
+	}
+	if Int.Help() != "a number" || String.Help() != "" {
+		t.Errorf("Help() = %q, %q, want %q, %q", Int.Help(), String.Help(), "a number", "")
+	}
+}
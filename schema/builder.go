@@ -1,6 +1,7 @@
 package schema
 
 import (
+	config "github.com/open-webtech/go-xaddy-config"
 	"github.com/open-webtech/go-xaddy-config/schema/nodes"
 )
 
@@ -11,3 +12,46 @@ type Builder struct {
 func NewBuilder() *Builder {
 	return &Builder{}
 }
+
+// Marshal renders the current values of the schema targets as configuration text,
+// e.g. to show the effective configuration after EvaluateTree.
+// See NodesContainer.DumpTree for what can be rendered.
+// Secret values are rendered as they are, see MarshalRedacted.
+func (b *Builder) Marshal(options ...nodes.DumpOption) ([]byte, error) {
+	return config.Marshal(b.DumpTree(options...))
+}
+
+// MarshalRedacted renders the current values of the schema targets like Marshal,
+// replacing the arguments that are secrets of the evaluated configuration with
+// config.Redacted. secrets are recorded with config.WithSecrets.
+func (b *Builder) MarshalRedacted(secrets *config.Secrets, options ...nodes.DumpOption) ([]byte, error) {
+	return config.Marshal(secrets.Redact(b.DumpTree(options...)))
+}
+
+// Evaluator returns a function that evaluates a configuration tree into a new T,
+// e.g. for config.NewWatcher. define is called for every tree to define the
+// schema targeting a fresh T, so that a failed evaluation leaves the values of
+// earlier evaluations untouched. Defaults can be set on the T before defining.
+func Evaluator[T any](define func(cfg *T) *Builder) func(config.AST) (*T, error) {
+	return func(ast config.AST) (*T, error) {
+		cfg := new(T)
+		if err := define(cfg).EvaluateTree(ast, cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+// ValuesEvaluator is like Evaluator, but the returned function also returns the
+// values of the evaluated configuration by path, see Provenance.Values. It is meant
+// for config.NewValuesWatcher, so that callbacks can subscribe to changes of values.
+func ValuesEvaluator[T any](define func(cfg *T) *Builder) func(config.AST) (*T, config.Values, error) {
+	return func(ast config.AST) (*T, config.Values, error) {
+		cfg := new(T)
+		p, err := define(cfg).EvaluateTreeWithProvenance(ast, cfg, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cfg, p.Values(), nil
+	}
+}
@@ -1,10 +1,18 @@
 package schema
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	config "github.com/open-webtech/go-xaddy-config"
 	"github.com/open-webtech/go-xaddy-config/schema/args"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
 )
 
 func TestNewBuilder(t *testing.T) {
@@ -200,4 +208,317 @@ func parseConfigString(content string) ([]parser.Node, error) {
 			},
 		},
 	}, nil
-}
\ No newline at end of file
+}
+
+func TestBuilderMarshal(t *testing.T) {
+	type Config struct {
+		LogLevel   string
+		ServerName string
+		Listen     string
+		Domains    []string
+	}
+
+	define := func(cfg *Config) *Builder {
+		builder := NewBuilder()
+		builder.DefineDirective("log_level", args.StringArg(&cfg.LogLevel))
+		serverBlock := builder.DefineBlock("server", args.StringArg(&cfg.ServerName))
+		serverBlock.DefineDirective("listen", args.StringArg(&cfg.Listen))
+		serverBlock.DefineDirective("domains", args.VariadicStringArg(&cfg.Domains))
+		return builder
+	}
+
+	cfg := &Config{LogLevel: "info", Listen: "25"}
+	builder := define(cfg)
+	err := builder.EvaluateTree([]parser.Node{
+		{Name: "server", Args: []string{"mail server"}, Children: []parser.Node{
+			{Name: "domains", Args: []string{"example.org", "example.com"}},
+		}},
+	}, cfg)
+	if err != nil {
+		t.Fatalf("Failed to evaluate config: %v", err)
+	}
+
+	data, err := builder.Marshal(nodes.OmitDefaults)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "server \"mail server\" {\n    domains example.org example.com\n}\n"
+	if string(data) != want {
+		t.Errorf("Marshal(OmitDefaults) = %q, want %q", data, want)
+	}
+
+	// The full dump must evaluate to the same configuration
+	data, err = builder.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	ast, err := config.Read(strings.NewReader(string(data)), "dump.conf")
+	if err != nil {
+		t.Fatalf("Failed to read dumped config: %v\n%s", err, data)
+	}
+	reread := &Config{}
+	if err := define(reread).EvaluateTree(ast, reread); err != nil {
+		t.Fatalf("Failed to evaluate dumped config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, reread) {
+		t.Errorf("Expected dumped config to evaluate to %+v, got %+v", cfg, reread)
+	}
+}
+
+func TestBuilderEvaluateJSON(t *testing.T) {
+	var logLevel, listen string
+	builder := NewBuilder()
+	builder.DefineDirective("log_level", args.StringArg(&logLevel))
+	builder.DefineBlock("server").DefineDirective("listen", args.StringArg(&listen))
+
+	ast, err := config.ReadJSON(strings.NewReader(`[
+		{"name": "log_level", "args": ["debug"]},
+		{"name": "server", "children": [{"name": "listen", "args": ["8080"]}]}
+	]`))
+	if err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+
+	if err := builder.EvaluateTree(ast, nil); err != nil {
+		t.Fatalf("Failed to evaluate config: %v", err)
+	}
+	if logLevel != "debug" || listen != "8080" {
+		t.Errorf("Expected log_level 'debug' and listen '8080', got '%s' and '%s'", logLevel, listen)
+	}
+}
+
+func TestBuilderEvaluateRedactsSecrets(t *testing.T) {
+	builder := NewBuilder()
+	builder.DefineDirectiveCallback("password", func(node parser.Node) error {
+		return nodes.NodeErr(node, "password %s is too short", node.Args[0])
+	})
+
+	var secrets config.Secrets
+	ast, err := config.Read(strings.NewReader("password {vault:db}"), "app.conf", config.WithSecrets(&secrets),
+		config.WithSecretResolver("vault", config.MapResolver(map[string]string{"db": "builder-secret"})))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	err = secrets.RedactError(builder.EvaluateTree(ast, nil))
+	if err == nil {
+		t.Fatal("Expected an error from the handler")
+	}
+	if want := "app.conf:1: password " + config.Redacted + " is too short"; err.Error() != want {
+		t.Errorf("Expected error %q, got %q", want, err.Error())
+	}
+}
+
+func TestBuilderMarshalRedacted(t *testing.T) {
+	var user, password string
+	builder := NewBuilder()
+	builder.DefineDirective("user", args.StringArg(&user))
+	builder.DefineDirective("password", args.StringArg(&password))
+
+	var secrets config.Secrets
+	ast, err := config.Read(strings.NewReader("user admin\npassword {vault:db}"), "app.conf", config.WithSecrets(&secrets),
+		config.WithSecretResolver("vault", config.MapResolver(map[string]string{"db": "builder-secret"})))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if err := builder.EvaluateTree(ast, nil); err != nil {
+		t.Fatalf("EvaluateTree() error = %v", err)
+	}
+
+	data, err := builder.MarshalRedacted(&secrets)
+	if err != nil {
+		t.Fatalf("MarshalRedacted() error = %v", err)
+	}
+	if want := "user admin\npassword " + config.Redacted + "\n"; string(data) != want {
+		t.Errorf("MarshalRedacted() = %q, want %q", data, want)
+	}
+}
+
+func TestBuilderMergeRules(t *testing.T) {
+	var logLevel string
+	var listen []string
+	builder := NewBuilder()
+	builder.DefineDirective("log_level", args.StringArg(&logLevel))
+	builder.DefineDirectiveCallback("listen", func(node parser.Node) error {
+		listen = append(listen, node.Args...)
+		return nil
+	}).SetAttrs(nodes.Repeatable)
+
+	base, err := config.Read(strings.NewReader("log_level info\nlisten 25"), "base.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	overlay, err := config.Read(strings.NewReader("log_level debug\nlisten 587"), "local.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	merged, err := config.Merge(base, builder, overlay)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if err := builder.EvaluateTree(merged, nil); err != nil {
+		t.Fatalf("Failed to evaluate merged config: %v", err)
+	}
+	if logLevel != "debug" || !reflect.DeepEqual(listen, []string{"25", "587"}) {
+		t.Errorf("Expected log_level 'debug' and listen [25 587], got '%s' and %v", logLevel, listen)
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	type Config struct {
+		LogLevel string
+		Port     int
+	}
+	evaluate := Evaluator(func(cfg *Config) *Builder {
+		cfg.LogLevel = "info"
+		builder := NewBuilder()
+		builder.DefineDirective("log_level", args.StringArg(&cfg.LogLevel))
+		builder.DefineDirective("port", args.IntArg(&cfg.Port))
+		return builder
+	})
+
+	first, err := evaluate(config.AST{{Name: "port", Args: []string{"25"}}})
+	if err != nil {
+		t.Fatalf("Failed to evaluate config: %v", err)
+	}
+	if first.LogLevel != "info" || first.Port != 25 {
+		t.Errorf("Expected log_level 'info' and port 25, got %+v", first)
+	}
+
+	if _, err := evaluate(config.AST{{Name: "port", Args: []string{"587"}}, {Name: "port", Args: []string{"465"}}}); err == nil {
+		t.Fatal("Expected an error for a repeated directive")
+	}
+	if first.Port != 25 {
+		t.Errorf("Failed evaluation modified an earlier result: %+v", first)
+	}
+}
+
+func TestValuesEvaluator(t *testing.T) {
+	type Config struct {
+		Listen int
+		Hosts  []string
+	}
+	evaluate := ValuesEvaluator(func(cfg *Config) *Builder {
+		cfg.Listen = 80
+		builder := NewBuilder()
+		builder.DefineDirective("listen", args.IntArg(&cfg.Listen))
+		builder.DefineDirective("host", args.VariadicStringArg(&cfg.Hosts)).SetAttrs(nodes.Repeatable)
+		builder.DefineDirectiveCallback("custom", func(parser.Node) error { return nil })
+		return builder
+	})
+
+	_, values, err := evaluate(config.AST{
+		{Name: "host", Args: []string{"a", "b"}},
+		{Name: "host", Args: []string{"c"}},
+		{Name: "custom", Args: []string{"x"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to evaluate config: %v", err)
+	}
+	want := config.Values{
+		"listen": {80},
+		"host":   {[]string{"a", "b", "c"}},
+		"custom": {"x"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected values %v, got %v", want, values)
+	}
+
+	if _, _, err := evaluate(config.AST{{Name: "listen", Args: []string{"x"}}}); err == nil {
+		t.Fatal("Expected an error for an invalid value")
+	}
+}
+
+func TestValuesEvaluatorWatcher(t *testing.T) {
+	type Config struct {
+		Listen int
+	}
+	file := filepath.Join(t.TempDir(), "app.conf")
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("server web {\n    listen 80\n}\n", time.Now())
+
+	w := config.NewValuesWatcher(file, ValuesEvaluator(func(cfg *Config) *Builder {
+		cfg.Listen = 80
+		builder := NewBuilder()
+		builder.DefineBlock("server", args.StringArg(new(string))).DefineDirective("listen", args.IntArg(&cfg.Listen))
+		return builder
+	}))
+	var calls []string
+	err := w.Subscribe("server[*]/listen", func(before, after config.Values) {
+		calls = append(calls, fmt.Sprint(before["server[web]/listen"], " -> ", after["server[web]/listen"]))
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// Removing the directive leaves its default, the same value.
+	write("server web {\n}\n", time.Now().Add(time.Second))
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	write("server web {\n    listen 443\n}\n", time.Now().Add(2*time.Second))
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"[80] -> [443]"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected subscription calls %q, got %q", want, calls)
+	}
+}
+
+func TestValuesEvaluatorWatcherRepeatedDirective(t *testing.T) {
+	type Config struct {
+		Allow []string
+	}
+	file := filepath.Join(t.TempDir(), "app.conf")
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("allow 10.0.0.0/8\nallow 192.168.0.0/16\n", time.Now())
+
+	w := config.NewValuesWatcher(file, ValuesEvaluator(func(cfg *Config) *Builder {
+		builder := NewBuilder()
+		builder.DefineDirectiveCallback("allow", func(node parser.Node) error {
+			cfg.Allow = append(cfg.Allow, node.Args...)
+			return nil
+		}).SetAttrs(nodes.Repeatable)
+		return builder
+	}))
+	var calls []string
+	err := w.Subscribe("allow", func(before, after config.Values) {
+		calls = append(calls, fmt.Sprint(before["allow"], " -> ", after["allow"]))
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// Changing an occurrence other than the last one is a change too.
+	write("allow 10.1.0.0/16\nallow 192.168.0.0/16\n", time.Now().Add(time.Second))
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	want := []string{"[10.0.0.0/8 192.168.0.0/16] -> [10.1.0.0/16 192.168.0.0/16]"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected subscription calls %q, got %q", want, calls)
+	}
+}
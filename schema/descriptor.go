@@ -0,0 +1,236 @@
+package schema
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/open-webtech/go-xaddy-config/schema/args"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// Descriptor is a machine-readable description of the directives and blocks of a
+// schema, e.g. for editor tooling or validators in other languages.
+type Descriptor struct {
+	Directives []NodeDescriptor `json:"directives,omitempty"`
+	Blocks     []NodeDescriptor `json:"blocks,omitempty"`
+}
+
+// NodeDescriptor describes a directive or block definition.
+type NodeDescriptor struct {
+	Name string `json:"name"`
+	// Args describes the argument definitions, none for nodes handled by callbacks
+	Args []ArgDescriptor `json:"args,omitempty"`
+	// MinArgs is the minimum number of arguments
+	MinArgs int `json:"min_args"`
+	// MaxArgs is the maximum number of arguments, -1 for no limit
+	MaxArgs    int      `json:"max_args"`
+	Repeatable bool     `json:"repeatable,omitempty"`
+	Doc        string   `json:"doc,omitempty"`
+	Examples   []string `json:"examples,omitempty"`
+	// Deprecated is the deprecation note of deprecated nodes
+	Deprecated string `json:"deprecated,omitempty"`
+	// Children describes the nodes of a block, nil for directives
+	Children *Descriptor `json:"children,omitempty"`
+}
+
+// ArgDescriptor describes an argument definition.
+type ArgDescriptor struct {
+	Name string `json:"name,omitempty"`
+	// Type is the Go type of the argument, e.g. "int"
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// Variadic arguments take all remaining arguments
+	Variadic bool `json:"variadic,omitempty"`
+	// Default is the value of optional arguments that aren't given
+	Default string `json:"default,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+}
+
+// Describe returns the descriptor of the directives and blocks defined in b.
+func (b *Builder) Describe() Descriptor {
+	return describeContainer(&b.NodesContainer)
+}
+
+func describeContainer(nc *nodes.NodesContainer) Descriptor {
+	var d Descriptor
+	for _, def := range nc.Directives {
+		d.Directives = append(d.Directives, describeNode(def))
+	}
+	for _, def := range nc.Blocks {
+		node := describeNode(def)
+		children := describeContainer(&def.NodesContainer)
+		node.Children = &children
+		d.Blocks = append(d.Blocks, node)
+	}
+	return d
+}
+
+func describeNode(def nodes.NodeDefinition) NodeDescriptor {
+	node := NodeDescriptor{
+		Name:       def.Name(),
+		MinArgs:    def.MinArgs(),
+		MaxArgs:    def.MaxArgs(),
+		Repeatable: def.Repeatable(),
+		Doc:        def.Documentation(),
+		Examples:   def.Examples(),
+		Deprecated: def.Deprecation(),
+	}
+	for _, arg := range def.Args() {
+		node.Args = append(node.Args, describeArg(arg))
+	}
+	return node
+}
+
+func describeArg(arg *args.ArgDef) ArgDescriptor {
+	d := ArgDescriptor{
+		Name:     arg.Name(),
+		Type:     arg.Type().String(),
+		Required: arg.Required(),
+		Variadic: arg.Variadic(),
+		Doc:      arg.Documentation(),
+	}
+	if !arg.Required() && !arg.Variadic() {
+		d.Default = arg.DefValue()
+	}
+	return d
+}
+
+// JSONSchemaURI is the JSON Schema dialect of the schemas returned by JSONSchema.
+const JSONSchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns a JSON Schema for the JSON form of configuration trees,
+// see config.AST.MarshalJSON, that checks the names, arguments and repetition
+// of the nodes defined in b. Like EvaluateTree, it allows nodes that aren't defined.
+// Argument values are checked as far as patterns allow, e.g. that an int argument
+// is a number.
+func (b *Builder) JSONSchema() ([]byte, error) {
+	schema := map[string]any{
+		"$schema": JSONSchemaURI,
+		"$defs": map[string]any{
+			"node": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":     map[string]any{"type": "string"},
+					"args":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"children": map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/node"}},
+					"snippet":  map[string]any{"type": "boolean"},
+					"macro":    map[string]any{"type": "boolean"},
+					"file":     map[string]any{"type": "string"},
+					"line":     map[string]any{"type": "integer"},
+				},
+				"required":             []string{"name"},
+				"additionalProperties": false,
+			},
+		},
+	}
+	for k, v := range containerSchema(b.Describe()) {
+		schema[k] = v
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// containerSchema returns the schema of the node list of a container.
+func containerSchema(d Descriptor) map[string]any {
+	var rules []any
+	for _, node := range slices.Concat(d.Directives, d.Blocks) {
+		isNode := map[string]any{
+			"properties": map[string]any{"name": map[string]any{"const": node.Name}},
+		}
+		if !node.Repeatable {
+			rules = append(rules, map[string]any{"contains": isNode, "maxContains": 1})
+		}
+	}
+
+	items := []any{map[string]any{"$ref": "#/$defs/node"}}
+	for _, node := range slices.Concat(d.Directives, d.Blocks) {
+		items = append(items, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{"name": map[string]any{"const": node.Name}},
+			},
+			"then": nodeSchema(node),
+		})
+	}
+
+	schema := map[string]any{"type": "array", "items": map[string]any{"allOf": items}}
+	if len(rules) != 0 {
+		schema["allOf"] = rules
+	}
+	return schema
+}
+
+// nodeSchema returns the schema of a node with the name of node.
+func nodeSchema(node NodeDescriptor) map[string]any {
+	argsSchema := map[string]any{"minItems": node.MinArgs}
+	if node.MaxArgs != -1 {
+		argsSchema["maxItems"] = node.MaxArgs
+	}
+	var prefix []any
+	for _, arg := range node.Args {
+		if arg.Variadic {
+			argsSchema["items"] = argSchema(arg)
+			break
+		}
+		prefix = append(prefix, argSchema(arg))
+	}
+	if len(prefix) != 0 {
+		argsSchema["prefixItems"] = prefix
+	}
+
+	properties := map[string]any{"args": argsSchema}
+	if node.Children != nil {
+		properties["children"] = containerSchema(*node.Children)
+	} else {
+		properties["children"] = map[string]any{"maxItems": 0}
+	}
+
+	schema := map[string]any{"properties": properties}
+	if description := nodeDescription(node); description != "" {
+		schema["description"] = description
+	}
+	if node.Deprecated != "" {
+		schema["deprecated"] = true
+	}
+	if node.MinArgs > 0 {
+		schema["required"] = []string{"args"}
+	}
+	return schema
+}
+
+// floatPattern matches the values accepted by strconv.ParseFloat.
+const floatPattern = `^[+-]?(([0-9](_?[0-9])*\.?([0-9](_?[0-9])*)?|\.[0-9](_?[0-9])*)([eE][+-]?[0-9](_?[0-9])*)?` +
+	`|0[xX]((_?[0-9a-fA-F])+\.?([0-9a-fA-F](_?[0-9a-fA-F])*)?|\.[0-9a-fA-F](_?[0-9a-fA-F])*)[pP][+-]?[0-9](_?[0-9])*` +
+	`|[iI][nN][fF]([iI][nN][iI][tT][yY])?|[nN][aA][nN])$`
+
+// argPatterns are the patterns argument values of the non-string types must match.
+var argPatterns = map[string]string{
+	"bool":    `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`,
+	"uint":    `^(0[xX](_?[0-9a-fA-F])+|0[oO](_?[0-7])+|0[bB](_?[01])+|0(_?[0-7])*|[1-9](_?[0-9])*)$`,
+	"int":     floatPattern,
+	"float32": floatPattern,
+	"float64": floatPattern,
+}
+
+func argSchema(arg ArgDescriptor) map[string]any {
+	schema := map[string]any{"type": "string"}
+	if pattern, ok := argPatterns[arg.Type]; ok {
+		schema["pattern"] = pattern
+	}
+	if arg.Name != "" {
+		schema["title"] = arg.Name
+	}
+	if arg.Doc != "" {
+		schema["description"] = arg.Doc
+	}
+	return schema
+}
+
+// nodeDescription returns the documentation of node followed by its deprecation note.
+func nodeDescription(node NodeDescriptor) string {
+	if node.Deprecated == "" {
+		return node.Doc
+	}
+	if node.Doc == "" {
+		return "Deprecated: " + node.Deprecated
+	}
+	return node.Doc + "\n\nDeprecated: " + node.Deprecated
+}
@@ -0,0 +1,186 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/args"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+func TestDescribe(t *testing.T) {
+	var (
+		level  string
+		port   int
+		hosts  []string
+		name   string
+		listen string
+	)
+	level = "info"
+	b := NewBuilder()
+	b.DefineDirective("log_level", args.StringArg(&level, args.Optional))
+	b.DefineDirective("port", args.IntArg(&port))
+	b.DefineDirectiveCallback("custom", func(parser.Node) error { return nil })
+	server := b.DefineBlock("server", args.StringArg(&name), args.VariadicStringArg(&hosts, args.Optional)).SetAttrs(nodes.Repeatable)
+	server.DefineDirective("listen", args.StringArg(&listen)).SetAttrs(nodes.Repeatable)
+
+	want := Descriptor{
+		Directives: []NodeDescriptor{
+			{Name: "log_level", Args: []ArgDescriptor{{Type: "string", Default: "info"}}, MinArgs: 0, MaxArgs: 1},
+			{Name: "port", Args: []ArgDescriptor{{Type: "int", Required: true}}, MinArgs: 1, MaxArgs: 1},
+			{Name: "custom", MinArgs: 0, MaxArgs: -1},
+		},
+		Blocks: []NodeDescriptor{
+			{
+				Name:       "server",
+				Args:       []ArgDescriptor{{Type: "string", Required: true}, {Type: "string", Variadic: true}},
+				MinArgs:    1,
+				MaxArgs:    -1,
+				Repeatable: true,
+				Children: &Descriptor{Directives: []NodeDescriptor{
+					{Name: "listen", Args: []ArgDescriptor{{Type: "string", Required: true}}, MinArgs: 1, MaxArgs: 1, Repeatable: true},
+				}},
+			},
+		},
+	}
+
+	got := b.Describe()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %+v, want %+v", got, want)
+	}
+
+	data, err := json.Marshal(got.Directives[1])
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `{"name":"port","args":[{"type":"int","required":true}],"min_args":1,"max_args":1}`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var port int
+	var listen string
+	b := NewBuilder()
+	b.DefineDirective("port", args.IntArg(&port))
+	b.DefineBlock("server").SetAttrs(nodes.Repeatable).DefineDirective("listen", args.StringArg(&listen))
+
+	data, err := b.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema() error = %v", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("JSONSchema() returned invalid JSON: %v", err)
+	}
+	if schema["$schema"] != JSONSchemaURI || schema["type"] != "array" {
+		t.Errorf("JSONSchema() = %s, want an array schema", data)
+	}
+
+	// Only port may not be repeated at the top level.
+	rules := schema["allOf"].([]any)
+	if len(rules) != 1 {
+		t.Fatalf("JSONSchema() has %d repetition rules, want 1", len(rules))
+	}
+	wantRule := map[string]any{
+		"contains":    map[string]any{"properties": map[string]any{"name": map[string]any{"const": "port"}}},
+		"maxContains": float64(1),
+	}
+	if !reflect.DeepEqual(rules[0], wantRule) {
+		t.Errorf("JSONSchema() repetition rule = %v, want %v", rules[0], wantRule)
+	}
+
+	items := schema["items"].(map[string]any)["allOf"].([]any)
+	if len(items) != 3 {
+		t.Fatalf("JSONSchema() has %d item schemas, want 3", len(items))
+	}
+	port1 := items[1].(map[string]any)["then"].(map[string]any)
+	wantPort := map[string]any{
+		"properties": map[string]any{
+			"args": map[string]any{
+				"minItems":    float64(1),
+				"maxItems":    float64(1),
+				"prefixItems": []any{map[string]any{"type": "string", "pattern": floatPattern}},
+			},
+			"children": map[string]any{"maxItems": float64(0)},
+		},
+		"required": []any{"args"},
+	}
+	if !reflect.DeepEqual(port1, wantPort) {
+		t.Errorf("JSONSchema() port schema = %v, want %v", port1, wantPort)
+	}
+
+	server := items[2].(map[string]any)["then"].(map[string]any)["properties"].(map[string]any)
+	children := server["children"].(map[string]any)
+	if children["type"] != "array" || children["allOf"] == nil {
+		t.Errorf("JSONSchema() server children schema = %v, want the schema of listen", children)
+	}
+}
+
+func TestArgPatterns(t *testing.T) {
+	inputs := []string{
+		"0", "1", "-1", "+1", "1.5", ".5", "5.", "1e3", "1E-3", "1_000", "1__0", "_1", "1_",
+		"0x1F", "0x_1F", "0X1p3", "0x1.8p1", "0x.8p1", "0x.p1", "0x1.8", "0b101", "0o17", "017", "08",
+		"inf", "-Inf", "Infinity", "nan", "NaN", "true", "F", "yes", "", "abc", "1e", "0x", "1.2.3", "++1",
+	}
+	parsers := map[string]func(string) error{
+		"bool":    func(s string) error { _, err := strconv.ParseBool(s); return err },
+		"uint":    func(s string) error { _, err := strconv.ParseUint(s, 0, 64); return err },
+		"int":     func(s string) error { _, err := strconv.ParseFloat(s, 64); return err },
+		"float32": func(s string) error { _, err := strconv.ParseFloat(s, 32); return err },
+		"float64": func(s string) error { _, err := strconv.ParseFloat(s, 64); return err },
+	}
+
+	for typ, parse := range parsers {
+		re := regexp.MustCompile(argPatterns[typ])
+		for _, s := range inputs {
+			if ok := parse(s) == nil; re.MatchString(s) != ok {
+				t.Errorf("pattern of %s matches %q = %v, want %v", typ, s, !ok, ok)
+			}
+		}
+	}
+}
+
+func TestDescribeDocumentation(t *testing.T) {
+	var port int
+	b := NewBuilder()
+	b.DefineDirective("port", args.IntArg(&port).SetName("port").Doc("The TCP port.")).
+		Doc("Sets the port to listen on.").
+		Example("port 8080").
+		Deprecate("use listen")
+
+	want := NodeDescriptor{
+		Name:       "port",
+		Args:       []ArgDescriptor{{Name: "port", Type: "int", Required: true, Doc: "The TCP port."}},
+		MinArgs:    1,
+		MaxArgs:    1,
+		Doc:        "Sets the port to listen on.",
+		Examples:   []string{"port 8080"},
+		Deprecated: "use listen",
+	}
+	if got := b.Describe().Directives[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Describe() = %+v, want %+v", got, want)
+	}
+
+	data, err := b.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema() error = %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("JSONSchema() returned invalid JSON: %v", err)
+	}
+	port1 := schema["items"].(map[string]any)["allOf"].([]any)[1].(map[string]any)["then"].(map[string]any)
+	if port1["description"] != "Sets the port to listen on.\n\nDeprecated: use listen" || port1["deprecated"] != true {
+		t.Errorf("JSONSchema() port schema = %v, want its documentation", port1)
+	}
+	arg := port1["properties"].(map[string]any)["args"].(map[string]any)["prefixItems"].([]any)[0].(map[string]any)
+	if arg["title"] != "port" || arg["description"] != "The TCP port." {
+		t.Errorf("JSONSchema() argument schema = %v, want its name and documentation", arg)
+	}
+}
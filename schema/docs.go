@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Docs renders a reference of the directives and blocks defined in b as Markdown,
+// with a section for each node: its syntax, documentation, deprecation note,
+// arguments and examples. Nodes within blocks follow their block, titled by
+// their path, e.g. "server/listen".
+func (b *Builder) Docs() []byte {
+	var buf bytes.Buffer
+	writeDocs(&buf, b.Describe(), "")
+	return buf.Bytes()
+}
+
+func writeDocs(buf *bytes.Buffer, d Descriptor, path string) {
+	for _, node := range d.Directives {
+		writeNodeDocs(buf, node, path+node.Name)
+	}
+	for _, node := range d.Blocks {
+		writeNodeDocs(buf, node, path+node.Name)
+		writeDocs(buf, *node.Children, path+node.Name+"/")
+	}
+}
+
+func writeNodeDocs(buf *bytes.Buffer, node NodeDescriptor, path string) {
+	if buf.Len() != 0 {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(buf, "## %s\n\n```\n%s\n```\n", path, synopsis(node))
+	if node.Deprecated != "" {
+		fmt.Fprintf(buf, "\n**Deprecated:** %s\n", node.Deprecated)
+	}
+	if node.Doc != "" {
+		fmt.Fprintf(buf, "\n%s\n", node.Doc)
+	}
+
+	if len(node.Args) != 0 {
+		buf.WriteString("\nArguments:\n\n")
+		for _, arg := range node.Args {
+			fmt.Fprintf(buf, "- `%s` (%s", argLabel(arg), arg.Type)
+			switch {
+			case arg.Default != "":
+				fmt.Fprintf(buf, ", default %q", arg.Default)
+			case !arg.Required:
+				buf.WriteString(", optional")
+			}
+			buf.WriteByte(')')
+			if arg.Doc != "" {
+				buf.WriteString(": ")
+				buf.WriteString(arg.Doc)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	if len(node.Examples) != 0 {
+		buf.WriteString("\nExamples:\n\n```\n")
+		for _, example := range node.Examples {
+			buf.WriteString(strings.TrimSuffix(example, "\n"))
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("```\n")
+	}
+}
+
+// synopsis returns the syntax of node, e.g. "listen <address> [port]".
+func synopsis(node NodeDescriptor) string {
+	parts := []string{node.Name}
+	for _, arg := range node.Args {
+		label := argLabel(arg)
+		if arg.Variadic {
+			label += "..."
+		}
+		if arg.Required {
+			parts = append(parts, "<"+label+">")
+		} else {
+			parts = append(parts, "["+label+"]")
+		}
+	}
+	if len(node.Args) == 0 && node.MaxArgs == -1 {
+		parts = append(parts, "...")
+	}
+	if node.Children != nil {
+		parts = append(parts, "{ ... }")
+	}
+	return strings.Join(parts, " ")
+}
+
+// argLabel returns the name of arg, or its type if it has none.
+func argLabel(arg ArgDescriptor) string {
+	if arg.Name != "" {
+		return arg.Name
+	}
+	return arg.Type
+}
@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/open-webtech/go-xaddy-config/schema/args"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+func TestDocs(t *testing.T) {
+	var (
+		level, name, address string
+		port                 int
+		hosts                []string
+	)
+	level = "info"
+	b := NewBuilder()
+	b.DefineDirective("log_level", args.StringArg(&level, args.Optional).SetName("level").Doc("The minimum level.")).
+		Doc("Sets the verbosity of the log.").
+		Example("log_level debug").
+		Deprecate("use `verbosity` instead.")
+	server := b.DefineBlock("server", args.StringArg(&name).SetName("name"), args.VariadicStringArg(&hosts, args.Optional)).
+		SetAttrs(nodes.Repeatable).
+		Doc("Defines a server.")
+	server.DefineDirective("listen", args.StringArg(&address).SetName("address"), args.IntArg(&port, args.Optional).SetName("port"))
+	server.DefineDirectiveCallback("custom", nil)
+
+	want := "## log_level\n\n```\nlog_level [level]\n```\n\n" +
+		"**Deprecated:** use `verbosity` instead.\n\n" +
+		"Sets the verbosity of the log.\n\n" +
+		"Arguments:\n\n- `level` (string, default \"info\"): The minimum level.\n\n" +
+		"Examples:\n\n```\nlog_level debug\n```\n" +
+		"\n## server\n\n```\nserver <name> [string...] { ... }\n```\n\n" +
+		"Defines a server.\n\n" +
+		"Arguments:\n\n- `name` (string)\n- `string` (string, optional)\n" +
+		"\n## server/listen\n\n```\nlisten <address> [port]\n```\n\n" +
+		"Arguments:\n\n- `address` (string)\n- `port` (int, default \"0\")\n" +
+		"\n## server/custom\n\n```\ncustom ...\n```\n"
+
+	if got := string(b.Docs()); got != want {
+		t.Errorf("Docs() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -32,6 +32,27 @@ func (d *BlockDef) SetAttrs(attributes ...NodeAttribute) *BlockDef {
 	return d
 }
 
+// Doc sets the description of the block, used in documentation generated from the schema.
+// It returns the block definition for method chaining.
+func (d *BlockDef) Doc(text string) *BlockDef {
+	d.doc = text
+	return d
+}
+
+// Example adds an example configuration snippet using the block.
+// It returns the block definition for method chaining.
+func (d *BlockDef) Example(config string) *BlockDef {
+	d.examples = append(d.examples, config)
+	return d
+}
+
+// Deprecate marks the block as deprecated, with a note such as what to use instead.
+// It returns the block definition for method chaining.
+func (d *BlockDef) Deprecate(note string) *BlockDef {
+	d.deprecated = note
+	return d
+}
+
 // SetHandler sets the handler function for the block definition.
 // It returns the block definition for method chaining.
 func (d *BlockDef) SetHandler(cb NodeHandler) *BlockDef {
@@ -41,11 +62,35 @@ func (d *BlockDef) SetHandler(cb NodeHandler) *BlockDef {
 
 // Evaluate processes a block node and its children, updating the configuration.
 func (d *BlockDef) Evaluate(node parser.Node, cfg any) error {
+	return d.evaluate(node, cfg, "", nil)
+}
+
+// evaluate evaluates the block node at path and its children, calling record if it is set.
+func (d *BlockDef) evaluate(node parser.Node, cfg any, path string, record EvaluationRecorder) error {
 	if err := evaluate(d, node); err != nil {
 		return err
 	}
+	if record != nil {
+		record(path, d, node)
+	}
 
-	return d.EvaluateTree(node.Children, cfg)
+	return d.evaluateTree(node.Children, cfg, path, record)
+}
+
+// Dump returns a node holding the current values of the block arguments and its children.
+// It reports false if there is nothing to render, or if only defaults would be rendered
+// and OmitDefaults is given.
+func (d *BlockDef) Dump(options ...DumpOption) (parser.Node, bool) {
+	args, isDefault := dumpArgs(d)
+	children := d.DumpTree(options...)
+	if len(d.args) == 0 && len(children) == 0 {
+		return parser.Node{}, false
+	}
+	if isDefault && len(children) == 0 && hasDumpOption(options, OmitDefaults) {
+		return parser.Node{}, false
+	}
+
+	return parser.Node{Name: d.name, Args: args, Children: children}, true
 }
 
 // ModuleBlockDef represents a block definition that can handle different module types.
@@ -58,8 +103,9 @@ type ModuleBlockDef struct {
 // NewModuleBlockDef creates a new module block definition with the given name.
 func NewModuleBlockDef(name string) *ModuleBlockDef {
 	d := &ModuleBlockDef{
-		modules:   make(map[string]*NodesContainer),
-		CommonDef: CommonDef{name: name},
+		modules:    make(map[string]*NodesContainer),
+		moduleName: new(string),
+		CommonDef:  CommonDef{name: name},
 	}
 	d.addArgs(args.StringArg(d.moduleName))
 	return d
@@ -72,6 +118,27 @@ func (d *ModuleBlockDef) WithArgs(args ...*args.ArgDef) *ModuleBlockDef {
 	return d
 }
 
+// Doc sets the description of the module block, used in documentation generated from the schema.
+// It returns the module block definition for method chaining.
+func (d *ModuleBlockDef) Doc(text string) *ModuleBlockDef {
+	d.doc = text
+	return d
+}
+
+// Example adds an example configuration snippet using the module block.
+// It returns the module block definition for method chaining.
+func (d *ModuleBlockDef) Example(config string) *ModuleBlockDef {
+	d.examples = append(d.examples, config)
+	return d
+}
+
+// Deprecate marks the module block as deprecated, with a note such as what to use instead.
+// It returns the module block definition for method chaining.
+func (d *ModuleBlockDef) Deprecate(note string) *ModuleBlockDef {
+	d.deprecated = note
+	return d
+}
+
 // Evaluate processes a module block node and its children, updating the configuration.
 func (d *ModuleBlockDef) Evaluate(node parser.Node, cfg any) error {
 	if err := evaluate(d, node); err != nil {
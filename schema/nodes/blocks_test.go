@@ -325,4 +325,20 @@ func TestModuleBlockDefEvaluate(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestBlockDefDocumentation(t *testing.T) {
+	block := NewBlockDef("server").Doc("Defines a server.").Example("server web {\n    listen :80\n}").Deprecate("use site")
+
+	if block.Documentation() != "Defines a server." || len(block.Examples()) != 1 || block.Deprecation() != "use site" {
+		t.Errorf("documentation = %q, %q, %q", block.Documentation(), block.Examples(), block.Deprecation())
+	}
+}
+
+func TestModuleBlockDefDocumentation(t *testing.T) {
+	block := NewModuleBlockDef("storage").Doc("Configures the storage backend.").Example("storage sql {\n}").Example("storage file {\n}").Deprecate("use store")
+
+	if block.Documentation() != "Configures the storage backend." || len(block.Examples()) != 2 || block.Deprecation() != "use store" {
+		t.Errorf("documentation = %q, %q, %q", block.Documentation(), block.Examples(), block.Deprecation())
+	}
+}
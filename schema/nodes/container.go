@@ -1,6 +1,8 @@
 package nodes
 
 import (
+	"strings"
+
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
 	"github.com/open-webtech/go-xaddy-config/schema/args"
 )
@@ -66,6 +68,17 @@ func (nc *NodesContainer) DefineDirectiveCallback(name string, cb NodeHandler) *
 // EvaluateTree evaluates and validates the configuration tree.
 // Returns an error if the evaluation fails.
 func (nc *NodesContainer) EvaluateTree(nodes []parser.Node, cfg any) error {
+	return nc.evaluateTree(nodes, cfg, "", nil)
+}
+
+// EvaluateTreeRecorded evaluates the configuration tree like EvaluateTree and calls
+// record for each node evaluated successfully, blocks before their children.
+func (nc *NodesContainer) EvaluateTreeRecorded(nodes []parser.Node, cfg any, record EvaluationRecorder) error {
+	return nc.evaluateTree(nodes, cfg, "", record)
+}
+
+// evaluateTree evaluates the nodes of the block at path, calling record if it is set.
+func (nc *NodesContainer) evaluateTree(nodes []parser.Node, cfg any, path string, record EvaluationRecorder) error {
 	var usedDirectives = make(map[string]bool)
 	var usedBlocks = make(map[string]bool)
 
@@ -80,6 +93,9 @@ func (nc *NodesContainer) EvaluateTree(nodes []parser.Node, cfg any) error {
 				if err := def.Evaluate(node, cfg); err != nil {
 					return err
 				}
+				if record != nil {
+					record(JoinPath(path, node), def, node)
+				}
 			}
 		}
 		for _, def := range nc.Blocks {
@@ -89,7 +105,7 @@ func (nc *NodesContainer) EvaluateTree(nodes []parser.Node, cfg any) error {
 				}
 
 				usedBlocks[node.Name] = true
-				if err := def.Evaluate(node, cfg); err != nil {
+				if err := def.evaluate(node, cfg, JoinPath(path, node), record); err != nil {
 					return err
 				}
 			}
@@ -98,3 +114,62 @@ func (nc *NodesContainer) EvaluateTree(nodes []parser.Node, cfg any) error {
 
 	return nil
 }
+
+// JoinPath returns the path of node within the block at path, the format of the
+// paths of evaluated nodes and of config.Diff. Block arguments are part of the
+// path, e.g. "server[web]/listen".
+func JoinPath(path string, node parser.Node) string {
+	segment := node.Name
+	if node.Children != nil && len(node.Args) != 0 {
+		segment += "[" + strings.Join(node.Args, " ") + "]"
+	}
+	if path == "" {
+		return segment
+	}
+	return path + "/" + segment
+}
+
+// RepeatableAt reports whether the node at path may be repeated. path holds the names
+// of the enclosing blocks followed by the name of the node. Unknown nodes are not repeatable.
+// This lets the container serve as config.MergeRules.
+func (nc *NodesContainer) RepeatableAt(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if len(path) == 1 {
+		for _, def := range nc.Directives {
+			if def.Name() == path[0] {
+				return def.Repeatable()
+			}
+		}
+	}
+	for _, def := range nc.Blocks {
+		if def.Name() != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return def.Repeatable()
+		}
+		return def.RepeatableAt(path[1:])
+	}
+	return false
+}
+
+// DumpTree renders the current values of all defined directives and blocks as configuration nodes,
+// directives first and blocks second, each in the order they were defined. This is the reverse of EvaluateTree, as far as the argument
+// targets allow: directives handled only by callbacks are skipped, and repeatable nodes are
+// rendered once, as their targets only hold a single value.
+func (nc *NodesContainer) DumpTree(options ...DumpOption) []parser.Node {
+	res := []parser.Node{}
+	for _, def := range nc.Directives {
+		if node, ok := def.Dump(options...); ok {
+			res = append(res, node)
+		}
+	}
+	for _, def := range nc.Blocks {
+		if node, ok := def.Dump(options...); ok {
+			res = append(res, node)
+		}
+	}
+	return res
+}
@@ -1,6 +1,8 @@
 package nodes
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
@@ -349,4 +351,152 @@ func TestNodesContainerRepeatableDirectives(t *testing.T) {
 			t.Errorf("Expected cfg.Values[%d] to be '%s', got '%s'", i, expected, cfg.Values[i])
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestNodesContainerDumpTree(t *testing.T) {
+	type Config struct {
+		LogLevel string
+		Server   string
+		Listen   string
+		TLS      bool
+	}
+	cfg := &Config{LogLevel: "info", Listen: "25"}
+
+	container := &NodesContainer{}
+	container.DefineDirective("log_level", args.StringArg(&cfg.LogLevel))
+	container.DefineDirectiveCallback("callback", func(parser.Node) error { return nil })
+	server := container.DefineBlock("server", args.StringArg(&cfg.Server))
+	server.DefineDirective("listen", args.StringArg(&cfg.Listen))
+	server.DefineDirective("tls", args.BoolArg(&cfg.TLS))
+
+	err := container.EvaluateTree([]parser.Node{
+		{Name: "server", Args: []string{"mx"}, Children: []parser.Node{
+			{Name: "tls", Args: []string{"true"}},
+		}},
+	}, cfg)
+	if err != nil {
+		t.Fatalf("EvaluateTree() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options []DumpOption
+		want    string
+	}{
+		{
+			name: "all values",
+			want: "log_level [info] [] server [mx] [listen [25] [] tls [true] []]",
+		},
+		{
+			name:    "omit defaults",
+			options: []DumpOption{OmitDefaults},
+			want:    "server [mx] [tls [true] []]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatNodes(container.DumpTree(tt.options...))
+			if got != tt.want {
+				t.Errorf("DumpTree() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatNodes renders names, arguments and children of nodes on a single line.
+func formatNodes(list []parser.Node) string {
+	parts := []string{}
+	for _, node := range list {
+		parts = append(parts, fmt.Sprintf("%s %v [%s]", node.Name, node.Args, formatNodes(node.Children)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestNodesContainerRepeatableAt(t *testing.T) {
+	var s string
+	container := &NodesContainer{}
+	container.DefineDirective("log_level", args.StringArg(&s))
+	container.DefineDirective("allow", args.StringArg(&s)).SetAttrs(Repeatable)
+	server := container.DefineBlock("server", args.StringArg(&s)).SetAttrs(Repeatable)
+	server.DefineDirective("listen", args.StringArg(&s)).SetAttrs(Repeatable)
+	server.DefineDirective("root", args.StringArg(&s))
+	server.DefineBlock("tls").DefineDirective("cert", args.StringArg(&s))
+
+	tests := []struct {
+		path []string
+		want bool
+	}{
+		{path: []string{"log_level"}, want: false},
+		{path: []string{"allow"}, want: true},
+		{path: []string{"server"}, want: true},
+		{path: []string{"server", "listen"}, want: true},
+		{path: []string{"server", "root"}, want: false},
+		{path: []string{"server", "tls"}, want: false},
+		{path: []string{"server", "tls", "cert"}, want: false},
+		{path: []string{"server", "unknown"}, want: false},
+		{path: []string{"allow", "listen"}, want: false},
+		{path: []string{"unknown"}, want: false},
+		{path: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := container.RepeatableAt(tt.path); got != tt.want {
+			t.Errorf("RepeatableAt(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNodesContainerEvaluateTreeRecorded(t *testing.T) {
+	var s string
+	var list []string
+	container := &NodesContainer{}
+	container.DefineDirective("log_level", args.StringArg(&s))
+	server := container.DefineBlock("server", args.VariadicStringArg(&list)).SetAttrs(Repeatable)
+	server.DefineDirective("listen", args.StringArg(&s))
+	server.DefineBlock("tls").DefineDirective("cert", args.StringArg(&s))
+
+	nodes := []parser.Node{
+		{Name: "log_level", Args: []string{"info"}},
+		{Name: "server", Args: []string{"web"}, Children: []parser.Node{
+			{Name: "listen", Args: []string{":80"}},
+			{Name: "tls", Children: []parser.Node{{Name: "cert", Args: []string{"a.pem"}}}},
+		}},
+		{Name: "server", Args: []string{"api", "v1"}, Children: []parser.Node{}},
+	}
+
+	var got []string
+	err := container.EvaluateTreeRecorded(nodes, nil, func(path string, def NodeDefinition, node parser.Node) {
+		if def.Name() != node.Name {
+			t.Errorf("recorded definition %q for node %q", def.Name(), node.Name)
+		}
+		got = append(got, path)
+	})
+	if err != nil {
+		t.Fatalf("EvaluateTreeRecorded() error = %v", err)
+	}
+
+	want := "log_level server[web] server[web]/listen server[web]/tls server[web]/tls/cert server[api v1]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("EvaluateTreeRecorded() recorded %q, want %q", got, want)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		path string
+		node parser.Node
+		want string
+	}{
+		{path: "", node: parser.Node{Name: "log_level", Args: []string{"info"}}, want: "log_level"},
+		{path: "", node: parser.Node{Name: "server", Args: []string{"web", "1"}, Children: []parser.Node{}}, want: "server[web 1]"},
+		{path: "server[web]", node: parser.Node{Name: "tls", Children: []parser.Node{}}, want: "server[web]/tls"},
+		{path: "server[web]/tls", node: parser.Node{Name: "cert_file", Args: []string{"cert.pem"}}, want: "server[web]/tls/cert_file"},
+	}
+
+	for _, tt := range tests {
+		if got := JoinPath(tt.path, tt.node); got != tt.want {
+			t.Errorf("JoinPath(%q, %v) = %q, want %q", tt.path, tt.node, got, tt.want)
+		}
+	}
+}
@@ -23,6 +23,12 @@ type NodeDefinition interface {
 	Handler() NodeHandler
 	// Repeatable returns whether this node can appear multiple times
 	Repeatable() bool
+	// Documentation returns the description of the node
+	Documentation() string
+	// Examples returns example configuration snippets using the node
+	Examples() []string
+	// Deprecation returns the deprecation note of the node, empty unless deprecated
+	Deprecation() string
 }
 
 // NodeEvaluator defines the interface for evaluating configuration nodes
@@ -34,6 +40,11 @@ type NodeEvaluator interface {
 // NodeHandler is a function type that processes a configuration node
 type NodeHandler func(node parser.Node) error
 
+// EvaluationRecorder is a function type that is told about each evaluated node.
+// path identifies the node within the tree, e.g. "server[web]/listen", with the
+// arguments of blocks in brackets.
+type EvaluationRecorder func(path string, def NodeDefinition, node parser.Node)
+
 // NodeAttribute represents special attributes that can be applied to nodes
 type NodeAttribute int
 
@@ -42,6 +53,14 @@ const (
 	Repeatable NodeAttribute = iota
 )
 
+// DumpOption represents options that control how the current configuration is rendered
+type DumpOption int
+
+const (
+	// OmitDefaults leaves out nodes whose arguments all hold their default values
+	OmitDefaults DumpOption = iota
+)
+
 // CommonDef provides common functionality for node definitions
 type CommonDef struct {
 	name       string         // name of the node
@@ -50,6 +69,9 @@ type CommonDef struct {
 	maxArgs    int            // maximum number of arguments allowed
 	handler    NodeHandler    // function to handle this node
 	repeatable bool           // whether this node can appear multiple times
+	doc        string         // description of the node
+	examples   []string       // example configuration using the node
+	deprecated string         // deprecation note, empty unless deprecated
 }
 
 func (d *CommonDef) Name() string {
@@ -68,6 +90,21 @@ func (d *CommonDef) Args() []*args.ArgDef {
 	return d.args
 }
 
+// Documentation returns the description of the node.
+func (d *CommonDef) Documentation() string {
+	return d.doc
+}
+
+// Examples returns the example configuration snippets using the node.
+func (d *CommonDef) Examples() []string {
+	return d.examples
+}
+
+// Deprecation returns the deprecation note of the node, or "" if it isn't deprecated.
+func (d *CommonDef) Deprecation() string {
+	return d.deprecated
+}
+
 func (d *CommonDef) addArgs(args ...*args.ArgDef) {
 	beginOptional := false
 
@@ -109,6 +146,9 @@ func evaluate(d NodeDefinition, node parser.Node) error {
 	}
 
 	if len(node.Args) < d.MinArgs() {
+		if name := d.Args()[len(node.Args)].Name(); name != "" {
+			return NodeErr(node, "directive '%s' expects at least %d arguments, missing argument '%s'", d.Name(), d.MinArgs(), name)
+		}
 		return NodeErr(node, "directive '%s' expects at least %d arguments", d.Name(), d.MinArgs())
 	}
 	if d.MaxArgs() != -1 && len(node.Args) > d.MaxArgs() {
@@ -117,11 +157,17 @@ func evaluate(d NodeDefinition, node parser.Node) error {
 
 	for i, arg := range d.Args() {
 		if arg.Variadic() {
-			values.SetList(arg.Target(), node.Args[i:])
+			for j, value := range node.Args[i:] {
+				if err := arg.Target().Set(value); err != nil {
+					return invalidArgErr(d, node, arg, i+j, value, err)
+				}
+			}
 			break
 		}
 		if i < len(node.Args) {
-			arg.Target().Set(node.Args[i])
+			if err := arg.Target().Set(node.Args[i]); err != nil {
+				return invalidArgErr(d, node, arg, i, node.Args[i], err)
+			}
 		}
 	}
 
@@ -131,3 +177,52 @@ func evaluate(d NodeDefinition, node parser.Node) error {
 
 	return nil
 }
+
+// invalidArgErr returns the error for the value at index i of the node arguments,
+// which arg failed to parse. The argument is named by its name, or else its position.
+func invalidArgErr(d NodeDefinition, node parser.Node, arg *args.ArgDef, i int, value string, err error) error {
+	label := fmt.Sprintf("argument %d", i+1)
+	if arg.Name() != "" {
+		label = fmt.Sprintf("argument '%s'", arg.Name())
+	}
+	if help := arg.Type().Help(); help != "" {
+		return NodeErr(node, "directive '%s': invalid %s %q, expected %s", d.Name(), label, value, help)
+	}
+	return NodeErr(node, "directive '%s': invalid %s %q: %v", d.Name(), label, value, err)
+}
+
+// dumpArgs returns the current string values of the node arguments and whether they all
+// equal their defaults. Trailing optional arguments holding their defaults are left out.
+func dumpArgs(d NodeDefinition) ([]string, bool) {
+	var res []string
+	isDefault := true
+	keep := 0
+
+	for _, arg := range d.Args() {
+		target := arg.Target()
+		if target.String() != arg.DefValue() {
+			isDefault = false
+		}
+
+		if list, ok := target.(values.ListValue); ok && arg.Variadic() {
+			res = append(res, list.Strings()...)
+		} else {
+			res = append(res, target.String())
+		}
+
+		if arg.Required() || target.String() != arg.DefValue() {
+			keep = len(res)
+		}
+	}
+
+	return res[:keep], isDefault
+}
+
+func hasDumpOption(options []DumpOption, option DumpOption) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -38,6 +38,27 @@ func (d *DirectiveDef) SetAttrs(attributes ...NodeAttribute) *DirectiveDef {
 	return d
 }
 
+// Doc sets the description of the directive, used in documentation generated from the schema.
+// Returns the directive definition for method chaining.
+func (d *DirectiveDef) Doc(text string) *DirectiveDef {
+	d.doc = text
+	return d
+}
+
+// Example adds an example configuration snippet using the directive.
+// Returns the directive definition for method chaining.
+func (d *DirectiveDef) Example(config string) *DirectiveDef {
+	d.examples = append(d.examples, config)
+	return d
+}
+
+// Deprecate marks the directive as deprecated, with a note such as what to use instead.
+// Returns the directive definition for method chaining.
+func (d *DirectiveDef) Deprecate(note string) *DirectiveDef {
+	d.deprecated = note
+	return d
+}
+
 // SetHandler sets the handler function for the directive definition.
 // Returns the directive definition for method chaining.
 func (d *DirectiveDef) SetHandler(cb NodeHandler) *DirectiveDef {
@@ -54,3 +75,19 @@ func (d *DirectiveDef) Evaluate(node parser.Node, cfg any) error {
 
 	return evaluate(d, node)
 }
+
+// Dump returns a node holding the current values of the directive arguments.
+// It reports false if the directive has no argument definitions, as its state is then
+// unknown, or if all arguments hold their defaults and OmitDefaults is given.
+func (d *DirectiveDef) Dump(options ...DumpOption) (parser.Node, bool) {
+	if len(d.args) == 0 {
+		return parser.Node{}, false
+	}
+
+	args, isDefault := dumpArgs(d)
+	if isDefault && hasDumpOption(options, OmitDefaults) {
+		return parser.Node{}, false
+	}
+
+	return parser.Node{Name: d.name, Args: args}, true
+}
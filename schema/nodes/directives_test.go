@@ -1,6 +1,7 @@
 package nodes
 
 import (
+	"strings"
 	"testing"
 
 	parser "github.com/foxcpp/maddy/framework/cfgparser"
@@ -271,4 +272,120 @@ func contains(s, substr string) bool {
 			}
 			return false
 		}()))
-}
\ No newline at end of file
+}
+
+func TestDirectiveDefDump(t *testing.T) {
+	host := "localhost"
+	port := 25
+	var tags []string
+
+	directive := NewDirectiveDef("listen",
+		args.StringArg(&host),
+		args.IntArg(&port, args.Optional),
+		args.VariadicStringArg(&tags, args.Optional),
+	)
+
+	// All arguments hold their defaults
+	node, ok := directive.Dump()
+	if !ok {
+		t.Fatal("Expected Dump() to render the directive")
+	}
+	if node.Name != "listen" || len(node.Args) != 1 || node.Args[0] != "localhost" {
+		t.Errorf("Expected 'listen localhost', got %s %v", node.Name, node.Args)
+	}
+	if _, ok := directive.Dump(OmitDefaults); ok {
+		t.Error("Expected Dump(OmitDefaults) to skip a directive holding defaults")
+	}
+
+	// A variadic value forces the optional arguments before it to be rendered
+	tags = []string{"a", "b"}
+	node, ok = directive.Dump(OmitDefaults)
+	if !ok {
+		t.Fatal("Expected Dump(OmitDefaults) to render a changed directive")
+	}
+	want := []string{"localhost", "25", "a", "b"}
+	if strings.Join(node.Args, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected args %v, got %v", want, node.Args)
+	}
+
+	// Directives without argument definitions have no known state
+	callback := NewDirectiveDef("callback").SetHandler(func(parser.Node) error { return nil })
+	if _, ok := callback.Dump(); ok {
+		t.Error("Expected Dump() to skip a directive without arguments")
+	}
+}
+
+func TestDirectiveDefInvalidArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     func() *DirectiveDef
+		args    []string
+		wantErr string
+	}{
+		{
+			name: "named argument",
+			def: func() *DirectiveDef {
+				var port int
+				return NewDirectiveDef("listen", args.IntArg(&port).SetName("port"))
+			},
+			args:    []string{"http"},
+			wantErr: `app.conf:3: directive 'listen': invalid argument 'port' "http", expected a number`,
+		},
+		{
+			name: "unnamed argument",
+			def: func() *DirectiveDef {
+				var host string
+				var tls bool
+				return NewDirectiveDef("listen", args.StringArg(&host), args.BoolArg(&tls))
+			},
+			args:    []string{"localhost", "yes"},
+			wantErr: `app.conf:3: directive 'listen': invalid argument 2 "yes", expected a boolean (true or false)`,
+		},
+		{
+			name: "variadic argument",
+			def: func() *DirectiveDef {
+				var ports []uint
+				return NewDirectiveDef("ports", args.VariadicUintArg(&ports))
+			},
+			args:    []string{"80", "443", "-1"},
+			wantErr: `app.conf:3: directive 'ports': invalid argument 3 "-1", expected a non-negative integer`,
+		},
+		{
+			name: "missing named argument",
+			def: func() *DirectiveDef {
+				var port int
+				return NewDirectiveDef("listen", args.IntArg(&port).SetName("port"))
+			},
+			args:    []string{},
+			wantErr: `app.conf:3: directive 'listen' expects at least 1 arguments, missing argument 'port'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := parser.Node{Name: tt.def().Name(), Args: tt.args, File: "app.conf", Line: 3}
+			err := tt.def().Evaluate(node, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDirectiveDefDocumentation(t *testing.T) {
+	directive := NewDirectiveDef("log_level").
+		Doc("Sets the minimum level of logged messages.").
+		Example("log_level debug").
+		Example("log_level error").
+		Deprecate("use verbosity instead")
+
+	if got := directive.Documentation(); got != "Sets the minimum level of logged messages." {
+		t.Errorf("Documentation() = %q", got)
+	}
+	if got := directive.Examples(); len(got) != 2 || got[0] != "log_level debug" || got[1] != "log_level error" {
+		t.Errorf("Examples() = %q", got)
+	}
+	if got := directive.Deprecation(); got != "use verbosity instead" {
+		t.Errorf("Deprecation() = %q", got)
+	}
+}
@@ -9,9 +9,10 @@ import (
 // NodeErr creates a formatted error message for configuration nodes.
 // If a file location is available, it prepends the file and line number to the error message.
 // If no file location is available, it returns a standard formatted error.
+// As with fmt.Errorf, an error argument formatted with %w is wrapped.
 func NodeErr(node parser.Node, errMsg string, args ...interface{}) error {
 	if node.File == "" {
 		return fmt.Errorf(errMsg, args...)
 	}
-	return fmt.Errorf("%s:%d: %s", node.File, node.Line, fmt.Sprintf(errMsg, args...))
+	return fmt.Errorf("%s:%d: "+errMsg, append([]interface{}{node.File, node.Line}, args...)...)
 }
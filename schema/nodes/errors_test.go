@@ -1,6 +1,7 @@
 package nodes
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -229,4 +230,17 @@ func TestNodeErrWithDifferentNodeTypes(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestNodeErrWraps(t *testing.T) {
+	cause := errors.New("permission denied")
+	for _, node := range []parser.Node{{Name: "import", File: "app.conf", Line: 3}, {Name: "import"}} {
+		err := NodeErr(node, "import of %s: %w", "denied.conf", cause)
+		if !errors.Is(err, cause) {
+			t.Errorf("NodeErr() = %v, want it to wrap the %%w argument", err)
+		}
+		if !strings.HasSuffix(err.Error(), "import of denied.conf: permission denied") {
+			t.Errorf("NodeErr() = %q, want the formatted message", err.Error())
+		}
+	}
+}
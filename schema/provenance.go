@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	config "github.com/open-webtech/go-xaddy-config"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+	"github.com/open-webtech/go-xaddy-config/schema/values"
+)
+
+// ProvenanceEntry tells where the value of a directive or block comes from.
+type ProvenanceEntry struct {
+	// Path identifies the node, e.g. "server[web]/listen". For defaults within
+	// blocks that weren't evaluated, blocks are identified by their name only.
+	Path string
+	// Origin is where the node was set, zero for defaults
+	Origin config.Origin
+	// Default is true if the configuration didn't set the node
+	Default bool
+	// Values holds the values of the argument targets after the node was evaluated,
+	// or the arguments themselves for nodes handled by callbacks. For defaults,
+	// these are the values the targets were left with.
+	Values []any
+}
+
+// String describes the entry, e.g. "set at /etc/app/site.conf:14 via import common_tls".
+func (e ProvenanceEntry) String() string {
+	if e.Default {
+		return "default"
+	}
+	return "set at " + e.Origin.String()
+}
+
+// Provenance records where the values of an evaluated configuration come from,
+// see Builder.EvaluateTreeWithProvenance.
+type Provenance struct {
+	entries []ProvenanceEntry
+	// paths maps node paths to the index of their last entry
+	paths map[string]int
+	// targets maps the pointers of argument targets to the index of their last entry
+	targets map[any]int
+	// values maps node paths to their values, see Values
+	values config.Values
+}
+
+// Entries returns all entries in evaluation order, followed by the defaults.
+// Repeated nodes have an entry for each occurrence.
+func (p *Provenance) Entries() []ProvenanceEntry {
+	return p.entries
+}
+
+// Lookup returns the entry of the node at path. For repeated nodes, this is the last one.
+func (p *Provenance) Lookup(path string) (ProvenanceEntry, bool) {
+	i, ok := p.paths[path]
+	if !ok {
+		return ProvenanceEntry{}, false
+	}
+	return p.entries[i], true
+}
+
+// Values returns the values of each path, e.g. for config.NewValuesWatcher.
+// For repeated nodes handled by callbacks, these are the arguments of all
+// occurrences in order, so that changing any occurrence changes the values.
+// For other repeated nodes, the targets hold the result of all occurrences,
+// and the values are those of the last entry.
+func (p *Provenance) Values() config.Values {
+	return maps.Clone(p.values)
+}
+
+// Of returns the entry of the node whose arguments target pointer, e.g. &cfg.Listen.
+// For targets set by several nodes, this is the last one.
+func (p *Provenance) Of(pointer any) (ProvenanceEntry, bool) {
+	i, ok := p.targets[pointer]
+	if !ok {
+		return ProvenanceEntry{}, false
+	}
+	return p.entries[i], true
+}
+
+// add adds entry for a node of def. Default entries don't replace the entries
+// of targets set by nodes.
+func (p *Provenance) add(entry ProvenanceEntry, def nodes.NodeDefinition) {
+	p.entries = append(p.entries, entry)
+	p.paths[entry.Path] = len(p.entries) - 1
+	if len(def.Args()) == 0 {
+		p.values[entry.Path] = append(p.values[entry.Path], entry.Values...)
+	} else {
+		p.values[entry.Path] = entry.Values
+	}
+	for _, arg := range def.Args() {
+		if target, ok := arg.Target().(values.PointerValue); ok {
+			if _, set := p.targets[target.Pointer()]; !set || !entry.Default {
+				p.targets[target.Pointer()] = len(p.entries) - 1
+			}
+		}
+	}
+}
+
+// addDefault adds a default entry for the directive def at path.
+func (p *Provenance) addDefault(def nodes.NodeDefinition, path string) {
+	p.add(ProvenanceEntry{Path: path, Default: true, Values: nodeValues(def, parser.Node{})}, def)
+}
+
+// addDefaults adds default entries for the directives of nc whose arguments weren't
+// set, descending into the blocks of nc that weren't evaluated.
+func (p *Provenance) addDefaults(nc *nodes.NodesContainer, path string, evaluated map[*nodes.BlockDef]bool) {
+	for _, def := range nc.Directives {
+		if len(def.Args()) != 0 && !p.anySet(def) {
+			p.addDefault(def, joinPath(path, def.Name()))
+		}
+	}
+	for _, def := range nc.Blocks {
+		if !evaluated[def] {
+			p.addDefaults(&def.NodesContainer, joinPath(path, def.Name()), evaluated)
+		}
+	}
+}
+
+// addBlockDefaults adds default entries for the directives of the block def
+// evaluated at path that the block doesn't contain.
+func (p *Provenance) addBlockDefaults(def *nodes.BlockDef, path string) {
+	for _, directive := range def.Directives {
+		directivePath := joinPath(path, directive.Name())
+		if _, ok := p.paths[directivePath]; !ok && len(directive.Args()) != 0 {
+			p.addDefault(directive, directivePath)
+		}
+	}
+}
+
+// anySet reports whether the target of an argument of def has an entry.
+func (p *Provenance) anySet(def nodes.NodeDefinition) bool {
+	for _, arg := range def.Args() {
+		if target, ok := arg.Target().(values.PointerValue); ok {
+			if _, ok := p.targets[target.Pointer()]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// EvaluateTreeWithProvenance evaluates tree like EvaluateTree and records where
+// each value comes from. origins, if the tree was read with config.WithOrigins,
+// adds snippets, imports and environment variables to the positions of the nodes.
+// Directives that weren't set get default entries: at the path of each evaluated
+// block that doesn't contain them, and by name for blocks that weren't evaluated.
+func (b *Builder) EvaluateTreeWithProvenance(tree []parser.Node, cfg any, origins *config.Origins) (*Provenance, error) {
+	p := &Provenance{paths: make(map[string]int), targets: make(map[any]int), values: make(config.Values)}
+	// blocks maps the paths of the evaluated blocks to their definitions
+	blocks := make(map[string]*nodes.BlockDef)
+	err := b.EvaluateTreeRecorded(tree, cfg, func(path string, def nodes.NodeDefinition, node parser.Node) {
+		p.add(ProvenanceEntry{Path: path, Origin: origins.Of(node), Values: nodeValues(def, node)}, def)
+		if block, ok := def.(*nodes.BlockDef); ok {
+			blocks[path] = block
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated := make(map[*nodes.BlockDef]bool)
+	for _, path := range slices.Sorted(maps.Keys(blocks)) {
+		p.addBlockDefaults(blocks[path], path)
+		evaluated[blocks[path]] = true
+	}
+	p.addDefaults(&b.NodesContainer, "", evaluated)
+	return p, nil
+}
+
+// nodeValues returns the current values of the argument targets of def, or the
+// arguments of node if def has no argument definitions.
+func nodeValues(def nodes.NodeDefinition, node parser.Node) []any {
+	var res []any
+	if len(def.Args()) == 0 {
+		for _, arg := range node.Args {
+			res = append(res, arg)
+		}
+		return res
+	}
+	for _, arg := range def.Args() {
+		res = append(res, copyValue(arg.Target().Get()))
+	}
+	return res
+}
+
+// copyValue returns value, dereferenced if it is a pointer, with slices copied
+// so that later evaluations don't change it.
+func copyValue(value any) any {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice && !v.IsNil() {
+		v = reflect.AppendSlice(reflect.MakeSlice(v.Type(), 0, v.Len()), v)
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
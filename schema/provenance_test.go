@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	config "github.com/open-webtech/go-xaddy-config"
+	"github.com/open-webtech/go-xaddy-config/schema/args"
+)
+
+func TestEvaluateTreeWithProvenance(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.conf": {Data: []byte("import site.conf\n")},
+		"site.conf": {Data: []byte("(common_tls) {\n  cert_file /etc/cert.pem\n}\n" +
+			"server web {\n  import common_tls\n  listen :80\n}\n")},
+	}
+	var origins config.Origins
+	ast, err := config.ReadFS(fsys, "app.conf", config.WithOrigins(&origins))
+	if err != nil {
+		t.Fatalf("ReadFS() error = %v", err)
+	}
+
+	var cfg struct {
+		Name, Listen, CertFile string
+		LogLevel               string
+	}
+	b := NewBuilder()
+	b.DefineDirective("log_level", args.StringArg(&cfg.LogLevel))
+	server := b.DefineBlock("server", args.StringArg(&cfg.Name))
+	server.DefineDirective("listen", args.StringArg(&cfg.Listen))
+	server.DefineDirective("cert_file", args.StringArg(&cfg.CertFile))
+
+	p, err := b.EvaluateTreeWithProvenance(ast, &cfg, &origins)
+	if err != nil {
+		t.Fatalf("EvaluateTreeWithProvenance() error = %v", err)
+	}
+
+	paths := []struct {
+		path string
+		want string
+	}{
+		{"server[web]", "set at site.conf:4, imported at app.conf:1"},
+		{"server[web]/listen", "set at site.conf:6, imported at app.conf:1"},
+		{"server[web]/cert_file", "set at site.conf:2 via import common_tls, imported at app.conf:1"},
+		{"log_level", "default"},
+	}
+	for _, tt := range paths {
+		t.Run(tt.path, func(t *testing.T) {
+			entry, ok := p.Lookup(tt.path)
+			if !ok {
+				t.Fatalf("Lookup(%q) found no entry", tt.path)
+			}
+			if entry.String() != tt.want {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.path, entry, tt.want)
+			}
+		})
+	}
+
+	if values := p.Values(); !reflect.DeepEqual(values["server[web]/listen"], []any{":80"}) || !reflect.DeepEqual(values["log_level"], []any{""}) {
+		t.Errorf("Values() = %v, want the values of listen and log_level", values)
+	}
+
+	if _, ok := p.Lookup("server"); ok {
+		t.Error("Lookup(\"server\") found an entry for a block set with arguments")
+	}
+	if entry, ok := p.Of(&cfg.Listen); !ok || entry.Path != "server[web]/listen" {
+		t.Errorf("Of(&cfg.Listen) = %v, %v, want server[web]/listen", entry, ok)
+	}
+	if entry, ok := p.Of(&cfg.LogLevel); !ok || !entry.Default {
+		t.Errorf("Of(&cfg.LogLevel) = %v, %v, want a default", entry, ok)
+	}
+}
+
+func TestEvaluateTreeWithProvenanceError(t *testing.T) {
+	var port int
+	b := NewBuilder()
+	b.DefineDirective("port", args.IntArg(&port))
+
+	ast, err := config.Read(strings.NewReader("port 1\nport 2\n"), "app.conf")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if _, err := b.EvaluateTreeWithProvenance(ast, nil, nil); err == nil {
+		t.Error("EvaluateTreeWithProvenance() expected an error for a repeated directive")
+	}
+}
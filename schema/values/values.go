@@ -19,6 +19,21 @@ type Value interface {
 	Get() any
 }
 
+// ListValue is implemented by values holding a list of elements, such as an Accumulator.
+type ListValue interface {
+	Value
+	// Strings returns the string representation of each element
+	Strings() []string
+}
+
+// PointerValue is implemented by values storing their value at a pointer, which
+// identifies the target of the value. All values in this package implement it.
+type PointerValue interface {
+	Value
+	// Pointer returns the pointer the value is stored at
+	Pointer() any
+}
+
 // Accumulator is a generic value collector that uses reflection to accumulate values into a slice.
 type Accumulator struct {
 	// element is a function that creates a Value for each element in the slice
@@ -47,12 +62,17 @@ func NewAccumulator(slice interface{}, element func(value interface{}) Value) *A
 
 // String returns a comma-separated string of all accumulated values.
 func (a *Accumulator) String() string {
+	return strings.Join(a.Strings(), ",")
+}
+
+// Strings returns the string representation of each accumulated value.
+func (a *Accumulator) Strings() []string {
 	out := []string{}
 	s := a.slice.Elem()
 	for i := 0; i < s.Len(); i++ {
 		out = append(out, a.element(s.Index(i).Addr().Interface()).String())
 	}
-	return strings.Join(out, ",")
+	return out
 }
 
 // Set adds a single value to the accumulated slice.
@@ -71,6 +91,11 @@ func (a *Accumulator) Get() interface{} {
 	return a.slice.Interface()
 }
 
+// Pointer returns the pointer to the target slice.
+func (a *Accumulator) Pointer() any {
+	return a.slice.Interface()
+}
+
 // IsCumulative indicates that this value type can accumulate multiple values.
 func (a *Accumulator) IsCumulative() bool {
 	return true
@@ -27,6 +27,9 @@ func (d *BoolValue) Set(s string) error {
 // Get returns the value of the BoolValue
 func (d *BoolValue) Get() interface{} { return (bool)(*d.v) }
 
+// Pointer returns the pointer the BoolValue stores its value at
+func (d *BoolValue) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the BoolValue
 func (d *BoolValue) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -63,6 +66,9 @@ func (d *StringValue) Set(s string) error {
 // Get returns the value of the StringValue
 func (d *StringValue) Get() interface{} { return (string)(*d.v) }
 
+// Pointer returns the pointer the StringValue stores its value at
+func (d *StringValue) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the StringValue
 func (d *StringValue) String() string { return string(*d.v) }
 
@@ -99,6 +105,9 @@ func (d *UintValue) Set(s string) error {
 // Get returns the value of the UintValue
 func (d *UintValue) Get() interface{} { return (uint)(*d.v) }
 
+// Pointer returns the pointer the UintValue stores its value at
+func (d *UintValue) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the UintValue
 func (d *UintValue) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -135,6 +144,9 @@ func (d *Uint8Value) Set(s string) error {
 // Get returns the value of the Uint8Value
 func (d *Uint8Value) Get() interface{} { return (uint8)(*d.v) }
 
+// Pointer returns the pointer the Uint8Value stores its value at
+func (d *Uint8Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Uint8Value
 func (d *Uint8Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -171,6 +183,9 @@ func (d *Uint16Value) Set(s string) error {
 // Get returns the value of the Uint16Value
 func (d *Uint16Value) Get() interface{} { return (uint16)(*d.v) }
 
+// Pointer returns the pointer the Uint16Value stores its value at
+func (d *Uint16Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Uint16Value
 func (d *Uint16Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -207,6 +222,9 @@ func (d *Uint32Value) Set(s string) error {
 // Get returns the value of the Uint32Value
 func (d *Uint32Value) Get() interface{} { return (uint32)(*d.v) }
 
+// Pointer returns the pointer the Uint32Value stores its value at
+func (d *Uint32Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Uint32Value
 func (d *Uint32Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -243,6 +261,9 @@ func (d *Uint64Value) Set(s string) error {
 // Get returns the value of the Uint64Value
 func (d *Uint64Value) Get() interface{} { return (uint64)(*d.v) }
 
+// Pointer returns the pointer the Uint64Value stores its value at
+func (d *Uint64Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Uint64Value
 func (d *Uint64Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -279,6 +300,9 @@ func (d *IntValue) Set(s string) error {
 // Get returns the value of the IntValue
 func (d *IntValue) Get() interface{} { return (int)(*d.v) }
 
+// Pointer returns the pointer the IntValue stores its value at
+func (d *IntValue) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the IntValue
 func (d *IntValue) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -315,6 +339,9 @@ func (d *Int8Value) Set(s string) error {
 // Get returns the value of the Int8Value
 func (d *Int8Value) Get() interface{} { return (int8)(*d.v) }
 
+// Pointer returns the pointer the Int8Value stores its value at
+func (d *Int8Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Int8Value
 func (d *Int8Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -351,6 +378,9 @@ func (d *Int16Value) Set(s string) error {
 // Get returns the value of the Int16Value
 func (d *Int16Value) Get() interface{} { return (int16)(*d.v) }
 
+// Pointer returns the pointer the Int16Value stores its value at
+func (d *Int16Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Int16Value
 func (d *Int16Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -387,6 +417,9 @@ func (d *Int32Value) Set(s string) error {
 // Get returns the value of the Int32Value
 func (d *Int32Value) Get() interface{} { return (int32)(*d.v) }
 
+// Pointer returns the pointer the Int32Value stores its value at
+func (d *Int32Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Int32Value
 func (d *Int32Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -423,6 +456,9 @@ func (d *Int64Value) Set(s string) error {
 // Get returns the value of the Int64Value
 func (d *Int64Value) Get() interface{} { return (int64)(*d.v) }
 
+// Pointer returns the pointer the Int64Value stores its value at
+func (d *Int64Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Int64Value
 func (d *Int64Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -459,6 +495,9 @@ func (d *Float32Value) Set(s string) error {
 // Get returns the value of the Float32Value
 func (d *Float32Value) Get() interface{} { return (float32)(*d.v) }
 
+// Pointer returns the pointer the Float32Value stores its value at
+func (d *Float32Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Float32Value
 func (d *Float32Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
@@ -495,6 +534,9 @@ func (d *Float64Value) Set(s string) error {
 // Get returns the value of the Float64Value
 func (d *Float64Value) Get() interface{} { return (float64)(*d.v) }
 
+// Pointer returns the pointer the Float64Value stores its value at
+func (d *Float64Value) Pointer() interface{} { return d.v }
+
 // String returns a string representation of the Float64Value
 func (d *Float64Value) String() string { return fmt.Sprintf("%v", *d.v) }
 
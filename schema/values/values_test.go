@@ -355,4 +355,31 @@ func TestAccumulator(t *testing.T) {
 			t.Errorf("Expected testStrings[%d] to be '%s', got '%s'", i, expected, testStrings[i])
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestAccumulatorStrings(t *testing.T) {
+	var testInts []int
+	accumulator := NewIntsValue(&testInts)
+
+	list, ok := accumulator.(ListValue)
+	if !ok {
+		t.Fatal("Expected accumulator to implement ListValue")
+	}
+
+	if got := list.Strings(); len(got) != 0 {
+		t.Errorf("Expected no strings, got %v", got)
+	}
+
+	SetList(accumulator, []string{"1", "22", "333"})
+
+	got := list.Strings()
+	want := []string{"1", "22", "333"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d strings, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected Strings()[%d] to be '%s', got '%s'", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+)
+
+// Redacted replaces secret values in redacted trees and error messages.
+const Redacted = "[redacted]"
+
+// Secrets records the secret values of a configuration, see WithSecrets. An
+// argument is secret if it contains the value of a {file:...} placeholder or of
+// a resolver passed to WithSecretResolver or registered with RegisterSecretResolver.
+// The whole expanded argument is recorded, e.g. a connection string embedding a
+// password, so redaction compares whole values.
+type Secrets struct {
+	values map[string]bool
+}
+
+// Contains reports whether value is a secret argument of the configuration.
+// A nil Secrets contains no values.
+func (s *Secrets) Contains(value string) bool {
+	return s != nil && s.values[value]
+}
+
+// Redact returns a copy of ast in which every argument that is a secret is
+// replaced with Redacted, e.g. to pass it to Marshal or json.Marshal for a dump.
+// Other arguments are kept as they are, even if they contain a secret value.
+func (s *Secrets) Redact(ast AST) AST {
+	return s.redactNodes(ast)
+}
+
+func (s *Secrets) redactNodes(list []parser.Node) []parser.Node {
+	if list == nil {
+		return nil
+	}
+	res := make([]parser.Node, len(list))
+	for i, node := range list {
+		if node.Args != nil {
+			args := make([]string, len(node.Args))
+			for j, arg := range node.Args {
+				if s.Contains(arg) {
+					arg = Redacted
+				}
+				args[j] = arg
+			}
+			node.Args = args
+		}
+		node.Children = s.redactNodes(node.Children)
+		res[i] = node
+	}
+	return res
+}
+
+// RedactError returns err with secret values redacted from its message, e.g.
+// an error returned by EvaluateTree before logging it. Only occurrences that
+// aren't part of a longer word are replaced, so a short secret doesn't mangle
+// the rest of the message. The original error remains available to errors.Is
+// and errors.As. A nil error is returned as is.
+func (s *Secrets) RedactError(err error) error {
+	if err == nil || s == nil {
+		return err
+	}
+	// Longer values first, so that a secret containing another one is redacted as a whole.
+	values := slices.Collect(maps.Keys(s.values))
+	slices.SortFunc(values, func(a, b string) int {
+		return cmp.Or(len(b)-len(a), strings.Compare(a, b))
+	})
+	msg := err.Error()
+	for _, value := range values {
+		msg = redactWord(msg, value)
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// redactWord replaces the occurrences of value in s that aren't directly preceded
+// or followed by a letter, digit or underscore.
+func redactWord(s, value string) string {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, value)
+		if i < 0 {
+			break
+		}
+		end := i + len(value)
+		if isWordEnd(s[:i], true) || isWordEnd(s[end:], false) {
+			sb.WriteString(s[:end])
+		} else {
+			sb.WriteString(s[:i])
+			sb.WriteString(Redacted)
+		}
+		s = s[end:]
+	}
+	if sb.Len() == 0 {
+		return s
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// isWordEnd reports whether s ends, or starts if last is false, with a word character.
+func isWordEnd(s string, last bool) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	if last {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// reset prepares s for recording a new read.
+func (s *Secrets) reset() {
+	s.values = make(map[string]bool)
+}
+
+// add records value as a secret. Empty values are ignored.
+func (s *Secrets) add(value string) {
+	if value != "" {
+		s.values[value] = true
+	}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
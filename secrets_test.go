@@ -0,0 +1,127 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretsRedact(t *testing.T) {
+	var secrets Secrets
+	vault := WithSecretResolver("vault", MapResolver(map[string]string{"db": "pw", "short": "a"}))
+	content := "password {vault:db}\ndsn postgres://app:{vault:db}@db/app\nname data {vault:short}\nserver {\n    token {vault:db}\n}\n"
+	ast, err := Read(strings.NewReader(content), "test.conf", vault, WithSecrets(&secrets))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	text, err := Marshal(secrets.Redact(ast))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "password [redacted]\ndsn [redacted]\nname data [redacted]\nserver {\n    token [redacted]\n}\n"
+	if string(text) != want {
+		t.Errorf("Marshal() of the redacted tree =\n%s\nwant\n%s", text, want)
+	}
+
+	data, err := json.Marshal(secrets.Redact(ast))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), "pw") {
+		t.Errorf("json.Marshal() of the redacted tree = %s, should not contain the secret", data)
+	}
+	if ast[0].Args[0] != "pw" {
+		t.Error("Redact() should not modify the tree")
+	}
+
+	// Without redaction, the tree is marshaled losslessly.
+	text, err = Marshal(ast)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	again, err := Read(strings.NewReader(string(text)), "test.conf")
+	if err != nil {
+		t.Fatalf("Read() of the marshaled tree error = %v", err)
+	}
+	if !equalNodes(ast, again) {
+		t.Errorf("Marshal() = %s, want the tree read", text)
+	}
+}
+
+func TestSecretsPerRead(t *testing.T) {
+	var secrets Secrets
+	vault := WithSecretResolver("vault", MapResolver(map[string]string{"old": "old-secret", "new": "new-secret"}))
+
+	if _, err := Read(strings.NewReader("password {vault:old}"), "test.conf", vault, WithSecrets(&secrets)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !secrets.Contains("old-secret") {
+		t.Error("Contains() = false for the secret of the read")
+	}
+
+	if _, err := Read(strings.NewReader("password {vault:new}"), "test.conf", vault, WithSecrets(&secrets)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if secrets.Contains("old-secret") || !secrets.Contains("new-secret") {
+		t.Error("Secrets should only hold the secrets of the last read")
+	}
+
+	var other Secrets
+	if _, err := Read(strings.NewReader("port 8080"), "test.conf", WithSecrets(&other)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if other.Contains("new-secret") {
+		t.Error("Secrets of one read should not affect another")
+	}
+
+	var none *Secrets
+	if none.Contains("new-secret") {
+		t.Error("a nil Secrets should contain no values")
+	}
+	if got := none.Redact(AST{{Name: "a", Args: []string{"b"}}}); got[0].Args[0] != "b" {
+		t.Errorf("Redact() with a nil Secrets = %v, want the tree as is", got)
+	}
+}
+
+func TestSecretsRedactError(t *testing.T) {
+	secrets := Secrets{values: map[string]bool{"a": true, "s3cret": true, "app:s3cret@db": true}}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "no secrets here", want: "no secrets here"},
+		{in: `invalid argument "s3cret"`, want: `invalid argument "[redacted]"`},
+		{in: "password s3cret is too short", want: "password [redacted] is too short"},
+		{in: "dsn postgres://app:s3cret@db", want: "dsn postgres://[redacted]"},
+		{in: "name data", want: "name data"},
+		{in: "is a value", want: "is [redacted] value"},
+		{in: "s3crets", want: "s3crets"},
+	}
+
+	for _, tt := range tests {
+		err := secrets.RedactError(errors.New(tt.in))
+		if err.Error() != tt.want {
+			t.Errorf("RedactError(%q) = %q, want %q", tt.in, err.Error(), tt.want)
+		}
+	}
+
+	if secrets.RedactError(nil) != nil {
+		t.Error("RedactError(nil) should return nil")
+	}
+	plain := errors.New("no secrets here")
+	if secrets.RedactError(plain) != plain {
+		t.Error("RedactError() should return errors without secrets as is")
+	}
+	err := secrets.RedactError(fmt.Errorf("wrapped: %w", plain))
+	if err.Error() != "wrapped: no secrets here" || !errors.Is(err, plain) {
+		t.Errorf("RedactError() = %v, want the wrapped error", err)
+	}
+	err = secrets.RedactError(fmt.Errorf("invalid s3cret: %w", plain))
+	if !errors.Is(err, plain) {
+		t.Error("RedactError() should keep the original error available")
+	}
+}
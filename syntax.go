@@ -0,0 +1,17 @@
+package config
+
+// SyntaxErrors parses src like Read and returns all syntax errors in it, in the
+// order they occur, or nil if there are none. Imports, conditional blocks and
+// placeholders aren't resolved, so errors in imported files aren't reported.
+//
+// Unlike Read, parsing doesn't stop at the first error: it resumes at the next
+// node outside of any block, so each top-level node reports at most one error.
+// A block or quoted string that is never closed extends to the end of the file.
+// Macros declared before an error remain defined. options may predefine macros,
+// other options have no effect. location is used in errors.
+func SyntaxErrors(src []byte, location string, options ...ReadOption) []error {
+	ctx := newLoader(osFS{}, options...).newParseContext(src, location)
+	ctx.recover = true
+	ctx.parseFile()
+	return ctx.errs
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "valid",
+			content: "$(port) = 80\nserver {\n    listen $(port)\n}\nimport missing\n",
+		},
+		{
+			name:    "each top-level node",
+			content: "1abc foo\nok 1\nserver {\n    listen :80 }\n}\nlast",
+			want: []string{
+				"test.conf:1: directive name 1abc starts with a digit",
+				`test.conf:5: unexpected "}" outside of a block`,
+			},
+		},
+		{
+			name:    "errors in blocks",
+			content: "a {\n    b {\n        $(m) = 1\n    }\n}\nc {\n    (snip) {\n    }\n}\n",
+			want: []string{
+				"test.conf:3: macro $(m) must be declared at the top level",
+				"test.conf:7: snippet (snip) must be declared at the top level",
+			},
+		},
+		{
+			name:    "unterminated quoted string",
+			content: "1a\nb \"c\nd\n",
+			want: []string{
+				"test.conf:1: directive name 1a starts with a digit",
+				"test.conf:2: unterminated quoted string",
+			},
+		},
+		{
+			name:    "unclosed block",
+			content: "a }\nserver {\n    listen :80\n",
+			want: []string{
+				`test.conf:1: unexpected "}" outside of a block`,
+				`test.conf:2: block is not closed, "}" expected before the end of the file`,
+			},
+		},
+		{
+			name:    "macros before an error",
+			content: "$(list) = a b\n1x\nname \"x $(list)\"\n",
+			want: []string{
+				"test.conf:2: directive name 1x starts with a digit",
+				"test.conf:3: macro $(list) has 2 values and can't be part of a longer argument",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range SyntaxErrors([]byte(tt.content), "test.conf") {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("SyntaxErrors() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			_, err := Read(strings.NewReader(tt.content), "test.conf", WithoutFileImports())
+			if len(tt.want) != 0 && (err == nil || err.Error() != tt.want[0]) {
+				t.Errorf("Read() error = %v, want the first syntax error", err)
+			}
+		})
+	}
+}
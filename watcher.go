@@ -0,0 +1,345 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+)
+
+// DefaultPollInterval is the interval at which a Watcher checks for changes by default.
+const DefaultPollInterval = 2 * time.Second
+
+// Watcher reloads a configuration file when it or any file it imports changes.
+// Changes are detected by polling the modification time and size of each file
+// and by matching the patterns of glob imports again, so that files added to or
+// removed from an imported directory are noticed as well.
+//
+// Each reload reads the configuration with ReadFile and passes it to the load
+// function, e.g. one returned by schema.Evaluator. The result is only published
+// if both succeed; otherwise the previous configuration stays current.
+//
+// OnReload and the callbacks registered with Subscribe are called after the
+// result has been published, outside the lock serializing reloads, so they may
+// call Reload themselves. They are called in the order the configurations were
+// published: if a reload publishes a configuration while the callbacks of an
+// earlier one are running, its callbacks are called by the Reload running them
+// once they return.
+//
+// The exported fields must be set before calling Reload or Run.
+type Watcher[T any] struct {
+	// Interval is the time between checks for changes, DefaultPollInterval if zero
+	Interval time.Duration
+	// ReadOptions are passed to ReadFile
+	ReadOptions []ReadOption
+	// OnReload is called with each published configuration, if set
+	OnReload func(cfg *T)
+	// OnError is called with the errors of reloads started by Run, if set
+	OnError func(err error)
+
+	filename string
+	load     func(AST) (*T, Values, error)
+	current  atomic.Pointer[T]
+	// withValues is set if load returns the values of the configuration
+	withValues bool
+
+	// mu serializes reloads and protects the fields below
+	mu       sync.Mutex
+	files    map[string]fileStamp
+	patterns map[string][]string
+	// values are the values of the current configuration
+	values Values
+
+	subscriptionsMu sync.Mutex
+	subscriptions   []subscription
+
+	// notifyMu protects the fields below
+	notifyMu sync.Mutex
+	// notifications call the callbacks of the published configurations whose
+	// callbacks haven't been called yet, in publish order
+	notifications []func()
+	// notifying is set while a Reload calls notifications
+	notifying bool
+}
+
+// Values maps the paths of the nodes of an evaluated configuration, such as
+// "server[web]/listen", to the values of their arguments, e.g. as returned by
+// schema.ValuesEvaluator. Block arguments are part of the path, see AST.Query.
+type Values map[string][]any
+
+// subscription is a callback registered with Subscribe.
+type subscription struct {
+	path []pathSegment
+	fn   func(before, after Values)
+}
+
+// fileStamp identifies the state of a file for change detection.
+type fileStamp struct {
+	exists  bool
+	modTime int64
+	size    int64
+}
+
+// NewWatcher creates a watcher for the configuration file filename. load turns
+// the configuration tree into the value to publish.
+func NewWatcher[T any](filename string, load func(AST) (*T, error)) *Watcher[T] {
+	return &Watcher[T]{filename: filename, load: func(ast AST) (*T, Values, error) {
+		cfg, err := load(ast)
+		return cfg, nil, err
+	}}
+}
+
+// NewValuesWatcher creates a watcher like NewWatcher, whose load function also
+// returns the values of the evaluated configuration, so that callbacks can
+// subscribe to changes, see Subscribe.
+func NewValuesWatcher[T any](filename string, load func(AST) (*T, Values, error)) *Watcher[T] {
+	return &Watcher[T]{filename: filename, load: load, withValues: true}
+}
+
+// Current returns the most recently published configuration, or nil if no
+// configuration has been loaded successfully yet. It is safe for concurrent use.
+func (w *Watcher[T]) Current() *T {
+	return w.current.Load()
+}
+
+// Reload reads and loads the configuration and publishes the result if this succeeds.
+// The files read are watched by Run from then on, even if the reload fails.
+func (w *Watcher[T]) Reload() error {
+	if err := w.reload(); err != nil {
+		return err
+	}
+	w.notify()
+	return nil
+}
+
+// reload reads, loads and publishes the configuration, and queues the call of
+// OnReload and the subscriptions whose values changed.
+func (w *Watcher[T]) reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var sources Sources
+	options := append(slices.Clip(w.ReadOptions), WithSources(&sources))
+	ast, err := ReadFile(w.filename, options...)
+	w.track(sources, err != nil)
+	if err != nil {
+		return err
+	}
+
+	cfg, values, err := w.load(ast)
+	if err != nil {
+		return err
+	}
+	first := w.current.Load() == nil
+	w.current.Store(cfg)
+	previous := w.values
+	w.values = values
+	changes := func() {}
+	if !first {
+		changes = w.changes(previous, values)
+	}
+
+	// The notification is queued before releasing mu, so that notifications
+	// are queued in publish order.
+	w.notifyMu.Lock()
+	w.notifications = append(w.notifications, func() {
+		if w.OnReload != nil {
+			w.OnReload(cfg)
+		}
+		changes()
+	})
+	w.notifyMu.Unlock()
+	return nil
+}
+
+// notify calls the queued notifications in order, unless another Reload is
+// already doing so, e.g. one whose callback called Reload.
+func (w *Watcher[T]) notify() {
+	w.notifyMu.Lock()
+	defer w.notifyMu.Unlock()
+	if w.notifying {
+		return
+	}
+	w.notifying = true
+	defer func() { w.notifying = false }()
+	for len(w.notifications) > 0 {
+		call := w.notifications[0]
+		w.notifications = w.notifications[1:]
+		func() {
+			w.notifyMu.Unlock()
+			defer w.notifyMu.Lock()
+			call()
+		}()
+	}
+}
+
+// Subscribe registers fn to be called after a reload that changes the values
+// at path, which requires a watcher created with NewValuesWatcher. fn receives
+// the values at the paths matching path in the previous and the new configuration.
+// Values that are evaluated to the same result, e.g. a directive that is removed
+// but defaults to its previous value, don't count as changes. fn isn't called for
+// the first load. See AST.Query for the syntax of path.
+func (w *Watcher[T]) Subscribe(path string, fn func(before, after Values)) error {
+	if !w.withValues {
+		return errors.New("subscriptions require a watcher created with NewValuesWatcher")
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	w.subscriptionsMu.Lock()
+	defer w.subscriptionsMu.Unlock()
+	w.subscriptions = append(w.subscriptions, subscription{path: segments, fn: fn})
+	return nil
+}
+
+// changes returns a function calling the subscriptions whose values differ.
+func (w *Watcher[T]) changes(previous, current Values) func() {
+	w.subscriptionsMu.Lock()
+	subscriptions := slices.Clone(w.subscriptions)
+	w.subscriptionsMu.Unlock()
+
+	var calls []func()
+	for _, s := range subscriptions {
+		before := previous.match(s.path)
+		after := current.match(s.path)
+		if !reflect.DeepEqual(before, after) {
+			calls = append(calls, func() { s.fn(before, after) })
+		}
+	}
+	return func() {
+		for _, call := range calls {
+			call()
+		}
+	}
+}
+
+// match returns the values whose paths match path.
+func (v Values) match(path []pathSegment) Values {
+	res := Values{}
+	for key, values := range v {
+		if matchesPath(key, path) {
+			res[key] = values
+		}
+	}
+	return res
+}
+
+// matchesPath reports whether the node path key, such as "server[web]/listen",
+// matches path.
+func matchesPath(key string, path []pathSegment) bool {
+	segments := splitPath(key)
+	if len(segments) != len(path) {
+		return false
+	}
+	for i, s := range segments {
+		name, args, hasArgs := strings.Cut(s, "[")
+		node := parser.Node{Name: name}
+		if hasArgs {
+			node.Args = strings.Fields(strings.TrimSuffix(args, "]"))
+		}
+		if !path[i].matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// Run checks for changes every Interval and reloads the configuration when
+// something changed, until ctx is done. It loads the configuration first if
+// Reload hasn't been called yet. Errors are passed to OnError.
+// Run returns the error of ctx.
+func (w *Watcher[T]) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	w.mu.Lock()
+	loaded := w.files != nil
+	w.mu.Unlock()
+	if !loaded {
+		w.reportError(w.Reload())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if w.changed() {
+				w.reportError(w.Reload())
+			}
+		}
+	}
+}
+
+func (w *Watcher[T]) reportError(err error) {
+	if err != nil && w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+// track records the current state of the sources. After a failed read, files
+// watched before remain watched, as the read may have stopped early.
+func (w *Watcher[T]) track(sources Sources, failed bool) {
+	names := append(sources.Files, w.filename)
+	patterns := sources.Patterns
+	if failed {
+		for name := range w.files {
+			names = append(names, name)
+		}
+		for pattern := range w.patterns {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	w.files = make(map[string]fileStamp, len(names))
+	for _, name := range names {
+		w.files[name] = statFile(name)
+	}
+
+	w.patterns = make(map[string][]string, len(patterns))
+	for _, pattern := range patterns {
+		w.patterns[pattern], _ = filepath.Glob(pattern)
+	}
+}
+
+// changed reports whether a watched file or the matches of a pattern changed.
+func (w *Watcher[T]) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for name, stamp := range w.files {
+		if statFile(name) != stamp {
+			return true
+		}
+	}
+	for pattern, matches := range w.patterns {
+		current, _ := filepath.Glob(pattern)
+		if !slices.Equal(current, matches) {
+			return true
+		}
+	}
+	return false
+}
+
+// statFile returns the current stamp of the named file.
+func statFile(name string) fileStamp {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, modTime: info.ModTime().UnixNano(), size: info.Size()}
+}
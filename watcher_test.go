@@ -0,0 +1,402 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	parser "github.com/foxcpp/maddy/framework/cfgparser"
+	"github.com/open-webtech/go-xaddy-config/schema/nodes"
+)
+
+// watchedConfig is the configuration loaded by the watcher tests.
+type watchedConfig struct {
+	names string
+}
+
+// loadNames loads the names of the top-level nodes, failing for a node named fail.
+func loadNames(ast AST) (*watchedConfig, error) {
+	for _, node := range ast {
+		if node.Name == "fail" {
+			return nil, errors.New("invalid configuration")
+		}
+	}
+	return &watchedConfig{names: nodeNames(ast)}, nil
+}
+
+// writeFile writes a file with a modification time distinct from earlier writes.
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "a\nimport base.conf")
+	writeFile(t, filepath.Join(dir, "base.conf"), "b")
+
+	w := NewWatcher(main, loadNames)
+	if w.Current() != nil {
+		t.Fatal("Current() should be nil before loading")
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := w.Current().names; got != "a b" {
+		t.Errorf("Current() = %q, want %q", got, "a b")
+	}
+
+	writeFile(t, main, "fail")
+	if err := w.Reload(); err == nil {
+		t.Error("Reload() should fail for an invalid configuration")
+	}
+	if got := w.Current().names; got != "a b" {
+		t.Errorf("Current() = %q after a failed reload, want %q", got, "a b")
+	}
+
+	writeFile(t, main, "a {")
+	if err := w.Reload(); err == nil {
+		t.Error("Reload() should fail for a syntax error")
+	}
+	if got := w.Current().names; got != "a b" {
+		t.Errorf("Current() = %q after a failed reload, want %q", got, "a b")
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "main\nimport base.conf\nimport conf.d/*.conf")
+	writeFile(t, filepath.Join(dir, "base.conf"), "base")
+	writeFile(t, filepath.Join(dir, "conf.d", "10-a.conf"), "a")
+
+	reloads := make(chan string, 16)
+	errs := make(chan error, 16)
+	w := NewWatcher(main, loadNames)
+	w.Interval = 10 * time.Millisecond
+	w.OnReload = func(cfg *watchedConfig) { reloads <- cfg.names }
+	w.OnError = func(err error) { errs <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	expectReload := func(want string) {
+		t.Helper()
+		select {
+		case got := <-reloads:
+			if got != want {
+				t.Errorf("reloaded %q, want %q", got, want)
+			}
+		case err := <-errs:
+			t.Fatalf("reload failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reload, want %q", want)
+		}
+	}
+
+	expectReload("main base a")
+
+	writeFile(t, filepath.Join(dir, "base.conf"), "base2")
+	expectReload("main base2 a")
+
+	writeFile(t, filepath.Join(dir, "conf.d", "20-b.conf"), "b")
+	expectReload("main base2 a b")
+
+	if err := os.Remove(filepath.Join(dir, "conf.d", "10-a.conf")); err != nil {
+		t.Fatal(err)
+	}
+	expectReload("main base2 b")
+
+	writeFile(t, filepath.Join(dir, "base.conf"), "fail")
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "invalid configuration") {
+			t.Errorf("OnError() got %v", err)
+		}
+	case names := <-reloads:
+		t.Fatalf("invalid configuration was published: %q", names)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+	if got := w.Current().names; got != "main base2 b" {
+		t.Errorf("Current() = %q after a failed reload, want %q", got, "main base2 b")
+	}
+
+	writeFile(t, filepath.Join(dir, "base.conf"), "base3")
+	expectReload("main base3 b")
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestWatcherMissingImport(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "main\nimport extra")
+
+	w := NewWatcher(main, loadNames)
+	if err := w.Reload(); err == nil {
+		t.Fatal("Reload() should fail for a missing import")
+	}
+	if w.changed() {
+		t.Fatal("changed() = true without changes")
+	}
+
+	writeFile(t, filepath.Join(dir, "extra.conf"), "extra")
+	if !w.changed() {
+		t.Fatal("changed() = false after creating the missing import")
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := w.Current().names; got != "main extra" {
+		t.Errorf("Current() = %q, want %q", got, "main extra")
+	}
+}
+
+func TestReadWithSources(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "import base\nimport conf.d/*.conf\npassword {file:secret}")
+	writeFile(t, filepath.Join(dir, "base.conf"), "base")
+	writeFile(t, filepath.Join(dir, "conf.d", "a.conf"), "a")
+	writeFile(t, filepath.Join(dir, "secret"), "s3cret")
+
+	var sources Sources
+	if _, err := ReadFile(main, WithSources(&sources)); err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	wantFiles := []string{
+		main,
+		filepath.Join(dir, "base"),
+		filepath.Join(dir, "base.conf"),
+		filepath.Join(dir, "conf.d", "a.conf"),
+		filepath.Join(dir, "secret"),
+	}
+	if strings.Join(sources.Files, "\n") != strings.Join(wantFiles, "\n") {
+		t.Errorf("Sources.Files = %q, want %q", sources.Files, wantFiles)
+	}
+	if want := filepath.Join(dir, "conf.d", "*.conf"); len(sources.Patterns) != 1 || sources.Patterns[0] != want {
+		t.Errorf("Sources.Patterns = %q, want [%q]", sources.Patterns, want)
+	}
+}
+
+func TestWatcherSubscribe(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "log_level info\nserver web {\n    listen 80\n}\nserver api {\n    listen 8080\n}")
+
+	w := NewValuesWatcher(main, loadValues)
+	var calls []string
+	subscribe := func(path string) {
+		t.Helper()
+		err := w.Subscribe(path, func(before, after Values) {
+			calls = append(calls, fmt.Sprintf("%s: %s -> %s", path, formatValues(before), formatValues(after)))
+		})
+		if err != nil {
+			t.Fatalf("Subscribe(%q) error = %v", path, err)
+		}
+	}
+	subscribe("server[*]/listen")
+	subscribe("server[api]/listen")
+	subscribe("log_level")
+
+	if err := w.Subscribe("server[web", nil); err == nil {
+		t.Error("Subscribe() should fail for an invalid path")
+	}
+	if err := NewWatcher(main, loadNames).Subscribe("log_level", nil); err == nil {
+		t.Error("Subscribe() should fail for a watcher without values")
+	}
+
+	reload := func(content string, want ...string) {
+		t.Helper()
+		calls = nil
+		writeFile(t, main, content)
+		if err := w.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+			t.Errorf("subscriptions called with\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	reload("log_level info\nserver web {\n    listen 80\n}\nserver api {\n    listen 8080\n}")
+	reload("log_level info\nserver web {\n    listen 443\n}\nserver api {\n    listen 8080\n}",
+		"server[*]/listen: server[api]/listen=8080 server[web]/listen=80 -> server[api]/listen=8080 server[web]/listen=443")
+	// log_level defaults to info, so removing it doesn't change its value.
+	reload("\nserver web {\n    listen 443\n}\nserver api {\n    listen 8080\n}")
+	reload("log_level debug\nserver web {\n    listen 443\n}\nserver api {\n    listen 8081\n}",
+		"server[*]/listen: server[api]/listen=8080 server[web]/listen=443 -> server[api]/listen=8081 server[web]/listen=443",
+		"server[api]/listen: server[api]/listen=8080 -> server[api]/listen=8081",
+		"log_level: log_level=info -> log_level=debug")
+	reload("log_level debug\nserver web {\n    listen 443\n}",
+		"server[*]/listen: server[api]/listen=8081 server[web]/listen=443 -> server[web]/listen=443",
+		"server[api]/listen: server[api]/listen=8081 -> ")
+
+	writeFile(t, main, "fail")
+	calls = nil
+	if err := w.Reload(); err == nil {
+		t.Fatal("Reload() should fail")
+	}
+	if len(calls) != 0 {
+		t.Errorf("subscriptions called for a failed reload: %q", calls)
+	}
+}
+
+func TestWatcherCallbacksMayReload(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "log_level info")
+
+	w := NewValuesWatcher(main, loadValues)
+	reloads := 0
+	w.OnReload = func(*watchedConfig) {
+		reloads++
+		if reloads == 2 {
+			if err := w.Reload(); err != nil {
+				t.Errorf("Reload() from OnReload error = %v", err)
+			}
+		}
+	}
+	err := w.Subscribe("log_level", func(before, after Values) {
+		if err := w.Reload(); err != nil {
+			t.Errorf("Reload() from a subscription error = %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+		writeFile(t, main, "log_level debug")
+		if err := w.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload() from a callback deadlocked")
+	}
+	if reloads != 4 {
+		t.Errorf("OnReload called %d times, want 4", reloads)
+	}
+}
+
+func TestWatcherCallbacksInPublishOrder(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "app.conf")
+	writeFile(t, main, "a")
+
+	// load numbers the configurations in publish order, since reloads are serialized.
+	published := 0
+	w := NewValuesWatcher(main, func(AST) (*int, Values, error) {
+		published++
+		n := published
+		return &n, Values{"n": {n}}, nil
+	})
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	w.OnReload = func(n *int) {
+		if *n == 2 {
+			// Another goroutine publishes the third configuration while the
+			// callbacks of the second one are running.
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if err := w.Reload(); err != nil {
+					t.Errorf("Reload() error = %v", err)
+				}
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Error("concurrent Reload() didn't return")
+			}
+		}
+		record(fmt.Sprint("reload ", *n))
+	}
+	err := w.Subscribe("n", func(before, after Values) {
+		record(fmt.Sprintf("n: %s -> %s", formatValues(before), formatValues(after)))
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	for range 2 {
+		if err := w.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	want := []string{"reload 1", "reload 2", "n: n=1 -> n=2", "reload 3", "n: n=2 -> n=3"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("callbacks called in the order\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// loadValues loads the names of the top-level nodes and the arguments of all
+// directives as values, with log_level defaulting to info.
+func loadValues(ast AST) (*watchedConfig, Values, error) {
+	cfg, err := loadNames(ast)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := Values{"log_level": {"info"}}
+	var add func(path string, list []parser.Node)
+	add = func(path string, list []parser.Node) {
+		for _, node := range list {
+			nodePath := nodes.JoinPath(path, node)
+			if node.Children != nil {
+				add(nodePath, node.Children)
+				continue
+			}
+			values[nodePath] = nil
+			for _, arg := range node.Args {
+				values[nodePath] = append(values[nodePath], arg)
+			}
+		}
+	}
+	add("", ast)
+	return cfg, values, nil
+}
+
+// formatValues formats values as path=values pairs, sorted by path.
+func formatValues(values Values) string {
+	var res []string
+	for path, v := range values {
+		res = append(res, fmt.Sprintf("%s=%s", path, strings.Trim(fmt.Sprint(v...), "[]")))
+	}
+	slices.Sort(res)
+	return strings.Join(res, " ")
+}